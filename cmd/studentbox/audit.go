@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/audit"
+)
+
+func auditCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "Query the audit log of operations on projects",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "user",
+				Aliases: []string{"u"},
+			},
+			&cli.StringFlag{
+				Name:    "project",
+				Aliases: []string{"p"},
+			},
+			&cli.StringFlag{
+				Name:  "action",
+				Usage: "Only this action (spawn, start, stop, destroy, update-env, extend, gc)",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Only entries after this date: a duration ago (24h), a date (2006-01-02) or RFC 3339",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "Only entries before this date, same formats as --since",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print entries as JSON lines",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			filter := audit.Filter{
+				User:    c.String("user"),
+				Project: c.String("project"),
+				Action:  c.String("action"),
+			}
+			now := time.Now()
+			if c.IsSet("since") {
+				if filter.Since, err = parseAuditTime(c.String("since"), now); err != nil {
+					return err
+				}
+			}
+			if c.IsSet("until") {
+				if filter.Until, err = parseAuditTime(c.String("until"), now); err != nil {
+					return err
+				}
+			}
+
+			entries, err := manager.QueryAudit(filter)
+			if err != nil {
+				return err
+			}
+
+			if c.Bool("json") {
+				enc := json.NewEncoder(c.App.Writer)
+				for _, entry := range entries {
+					if err := enc.Encode(entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			if len(entries) == 0 {
+				fmt.Fprintln(c.App.Writer, "No entries")
+				return nil
+			}
+			for _, entry := range entries {
+				line := fmt.Sprintf("%s %s %s", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Principal, entry.Action)
+				if entry.User != "" {
+					line += fmt.Sprintf(" %s/%s", entry.User, entry.Project)
+				}
+				if entry.Runtime != "" {
+					line += " (" + entry.Runtime + ")"
+				}
+				if entry.Detail != "" {
+					line += " " + entry.Detail
+				}
+				if len(entry.EnvVars) > 0 {
+					line += " env: " + strings.Join(entry.EnvVars, ",")
+				}
+				line += ": " + entry.Result
+				if entry.Error != "" {
+					line += ": " + entry.Error
+				}
+				fmt.Fprintln(c.App.Writer, line)
+			}
+			return nil
+		},
+	}
+}
+
+// Like parseExpires, but durations are in the past
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return parseExpires(value, now)
+}
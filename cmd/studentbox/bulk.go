@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/bulk"
+	"github.com/sinux-l5d/studentbox/internal/containers"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+// Subcommands acting on every project of a roster
+func bulkCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.PathFlag{
+			Name:     "roster",
+			Usage:    "CSV file with a header row, a user column, an optional project column and env var columns ([image:]NAME, upper case)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "project",
+			Aliases: []string{"p"},
+			Usage:   "Project of rows without a project column",
+		},
+		&cli.IntFlag{
+			Name:  "workers",
+			Usage: "Number of projects handled concurrently",
+			Value: 4,
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only print what would be done",
+		},
+		&cli.PathFlag{
+			Name:  "report",
+			Usage: "Write the JSON report to this file instead of stdout",
+		},
+		&cli.PathFlag{
+			Name:  "resume",
+			Usage: "Skip projects that succeeded in this previous report",
+		},
+	}
+
+	return &cli.Command{
+		Name:  "bulk",
+		Usage: "Act on every project of a roster",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "spawn",
+				Usage: "Spawn a runtime for every project of the roster",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "runtime",
+						Aliases:  []string{"r"},
+						Required: true,
+					},
+					pullFlag(),
+					removePulledFlag(),
+				}, flags...),
+				Action: func(c *cli.Context) error {
+					runtime, exists := runtimes.OfficialRuntimes[c.String("runtime")]
+					if !exists {
+						return fmt.Errorf("runtime %s doesn't exist", c.String("runtime"))
+					}
+
+					return runBulk(c, "spawn", func(manager *containers.Manager, entry bulk.Entry) error {
+						exists, err := manager.PodExists(entry.User, entry.Project)
+						if err != nil {
+							return err
+						}
+						if exists {
+							return &bulk.ErrSkipped{Reason: "already exists"}
+						}
+						return manager.SpawnPod(&containers.PodOptions{
+							User:               entry.User,
+							Project:            entry.Project,
+							InputEnvVars:       entry.EnvVars.Global,
+							ImageEnvVars:       entry.EnvVars.PerImage,
+							Runtime:            runtime,
+							PullPolicy:         c.String("pull"),
+							RemovePulledImages: c.Bool("remove-pulled"),
+						})
+					})
+				},
+			},
+			{
+				Name:  "stop",
+				Usage: "Stop every project of the roster",
+				Flags: flags,
+				Action: func(c *cli.Context) error {
+					return runBulk(c, "stop", func(manager *containers.Manager, entry bulk.Entry) error {
+						return manager.StopPod(entry.User, entry.Project)
+					})
+				},
+			},
+			{
+				Name:  "destroy",
+				Usage: "Remove the pod of every project of the roster, keeping data",
+				Flags: flags,
+				Action: func(c *cli.Context) error {
+					return runBulk(c, "destroy", func(manager *containers.Manager, entry bulk.Entry) error {
+						return manager.DestroyPod(entry.User, entry.Project)
+					})
+				},
+			},
+		},
+	}
+}
+
+func runBulk(c *cli.Context, action string, do func(*containers.Manager, bulk.Entry) error) error {
+	f, err := os.Open(c.Path("roster"))
+	if err != nil {
+		return err
+	}
+	entries, err := bulk.ReadRoster(f, c.String("project"))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read roster: %w", err)
+	}
+
+	var previous *bulk.Report
+	if c.Path("resume") != "" {
+		f, err := os.Open(c.Path("resume"))
+		if err != nil {
+			return err
+		}
+		previous, err = bulk.ReadReport(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read previous report: %w", err)
+		}
+		if previous.Action != action {
+			return fmt.Errorf("previous report is for %s, not %s", previous.Action, action)
+		}
+	}
+
+	manager, err := newManager(c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.Bool("dry-run")
+	run := func(entry bulk.Entry) error {
+		return do(manager, entry)
+	}
+	if dryRun {
+		run = bulk.DryRun(c.App.ErrWriter, action)
+	}
+	if previous != nil {
+		run = bulk.Resume(previous, run)
+	}
+	report := bulk.Run(action, entries, c.Int("workers"), run)
+	report.DryRun = dryRun
+
+	out := c.App.Writer
+	if c.Path("report") != "" {
+		f, err := os.Create(c.Path("report"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := report.Write(out); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.App.ErrWriter, "%s: %d succeeded, %d failed, %d skipped\n", action, report.Succeeded, report.Failed, report.Skipped)
+	if report.Failed > 0 {
+		return fmt.Errorf("%d project(s) failed", report.Failed)
+	}
+	return nil
+}
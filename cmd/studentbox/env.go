@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+// Subcommands to update environment variables of a running project
+func envCommand() *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     "user",
+			Aliases:  []string{"u"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "project",
+			Aliases:  []string{"p"},
+			Required: true,
+		},
+	}
+
+	return &cli.Command{
+		Name:  "env",
+		Usage: "Update environment variables of a project's runtime, recreating affected containers",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "set",
+				Usage:     "Set environment variables, optionally for a single container",
+				ArgsUsage: "[image:]NAME=value...",
+				Flags:     flags,
+				Action: func(c *cli.Context) error {
+					envvar := runtimes.NewInputEnvVars()
+					for _, value := range c.Args().Slice() {
+						if err := envvar.Parse(value); err != nil {
+							return err
+						}
+					}
+
+					set := make(map[string]containers.EnvVarChanges)
+					for name, value := range envvar.Global {
+						changes := set[""]
+						if changes.Set == nil {
+							changes.Set = make(map[string]string)
+						}
+						changes.Set[name] = value
+						set[""] = changes
+					}
+					for image, envs := range envvar.PerImage {
+						set[image] = containers.EnvVarChanges{Set: envs}
+					}
+					return updateEnvVars(c, set)
+				},
+			},
+			{
+				Name:      "unset",
+				Usage:     "Unset environment variables, optionally for a single container",
+				ArgsUsage: "[image:]NAME...",
+				Flags:     flags,
+				Action: func(c *cli.Context) error {
+					unset := make(map[string]containers.EnvVarChanges)
+					for _, value := range c.Args().Slice() {
+						image, name, scoped := strings.Cut(value, ":")
+						if !scoped {
+							image, name = "", value
+						}
+						if name == "" || strings.Contains(name, "=") {
+							return fmt.Errorf("invalid environment variable name %s", value)
+						}
+						changes := unset[image]
+						changes.Unset = append(changes.Unset, name)
+						unset[image] = changes
+					}
+					return updateEnvVars(c, unset)
+				},
+			},
+		},
+	}
+}
+
+// Apply changes grouped by image short name, "" meaning all images
+// Each container is recreated at most once
+func updateEnvVars(c *cli.Context, changes map[string]containers.EnvVarChanges) error {
+	if len(changes) == 0 {
+		return fmt.Errorf("no environment variable given")
+	}
+
+	manager, err := newManager(c.App.ErrWriter)
+	if err != nil {
+		return err
+	}
+
+	user, project := c.String("user"), c.String("project")
+	runtime, err := manager.GetRuntime(user, project)
+	if err != nil {
+		return err
+	}
+	plan, err := planEnvChanges(runtime, changes)
+	if err != nil {
+		return err
+	}
+
+	images := make([]string, 0, len(plan))
+	for image := range plan {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	for _, image := range images {
+		if err := manager.UpdateEnvVars(user, project, image, plan[image]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "Updated container %s\n", image)
+	}
+	return nil
+}
+
+// Merge changes grouped by image short name, "" meaning all images, into the changes of each container
+// Shared images are left out of global changes, and rejected before anything is recreated if explicitly changed
+func planEnvChanges(runtime runtimes.Runtime, changes map[string]containers.EnvVarChanges) (map[string]containers.EnvVarChanges, error) {
+	for image := range changes {
+		if image == "" {
+			continue
+		}
+		img, exists := runtime.Images[image]
+		if !exists {
+			return nil, &containers.ErrImageNotInRuntime{Image: image, Runtime: runtime.Name}
+		}
+		if img.Shared {
+			return nil, &containers.ErrSharedImage{Image: image}
+		}
+	}
+
+	global := changes[""]
+	plan := make(map[string]containers.EnvVarChanges)
+	for image, img := range runtime.Images {
+		if img.Shared {
+			continue
+		}
+		merged := containers.EnvVarChanges{
+			Set:   make(map[string]string),
+			Unset: append(append([]string{}, global.Unset...), changes[image].Unset...),
+		}
+		for k, v := range global.Set {
+			merged.Set[k] = v
+		}
+		for k, v := range changes[image].Set {
+			merged.Set[k] = v
+		}
+		if len(merged.Set) == 0 && len(merged.Unset) == 0 {
+			continue
+		}
+		plan[image] = merged
+	}
+	return plan, nil
+}
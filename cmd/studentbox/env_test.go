@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+func TestPlanEnvChanges(t *testing.T) {
+	runtime := runtimes.Runtime{Name: "lamp", Images: map[string]runtimes.Image{
+		"mysql":  {ShortName: "mysql", Shared: true},
+		"php":    {ShortName: "php"},
+		"apache": {ShortName: "apache"},
+	}}
+
+	t.Run("global set skips shared images", func(t *testing.T) {
+		plan, err := planEnvChanges(runtime, map[string]containers.EnvVarChanges{
+			"":    {Set: map[string]string{"TZ": "Europe/Paris"}},
+			"php": {Set: map[string]string{"DEBUG": "1"}, Unset: []string{"OLD"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, exists := plan["mysql"]; exists || len(plan) != 2 {
+			t.Fatalf("expected php and apache only, got %+v", plan)
+		}
+		if plan["apache"].Set["TZ"] != "Europe/Paris" || len(plan["apache"].Set) != 1 {
+			t.Errorf("unexpected apache changes %+v", plan["apache"])
+		}
+		if plan["php"].Set["TZ"] != "Europe/Paris" || plan["php"].Set["DEBUG"] != "1" || len(plan["php"].Unset) != 1 {
+			t.Errorf("unexpected php changes %+v", plan["php"])
+		}
+	})
+
+	t.Run("explicit shared image", func(t *testing.T) {
+		_, err := planEnvChanges(runtime, map[string]containers.EnvVarChanges{
+			"php":   {Set: map[string]string{"DEBUG": "1"}},
+			"mysql": {Set: map[string]string{"MARIADB_PASSWORD": "x"}},
+		})
+		var shared *containers.ErrSharedImage
+		if !errors.As(err, &shared) {
+			t.Errorf("expected ErrSharedImage, got %v", err)
+		}
+	})
+
+	t.Run("unknown image", func(t *testing.T) {
+		_, err := planEnvChanges(runtime, map[string]containers.EnvVarChanges{"redis": {Unset: []string{"X"}}})
+		var notInRuntime *containers.ErrImageNotInRuntime
+		if !errors.As(err, &notInRuntime) {
+			t.Errorf("expected ErrImageNotInRuntime, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func eventsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "events",
+		Usage: "Print lifecycle events of projects' pods and containers",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "user",
+				Aliases: []string{"u"},
+			},
+			&cli.StringFlag{
+				Name:    "project",
+				Aliases: []string{"p"},
+			},
+			&cli.StringSliceFlag{
+				Name:  "type",
+				Usage: "Only events of this type, can be repeated (e.g. container-died, health-changed)",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Print past events since this date: a duration ago (1h), a date (2006-01-02) or RFC 3339",
+			},
+			&cli.BoolFlag{
+				Name:    "follow",
+				Aliases: []string{"f"},
+				Usage:   "Keep printing new events",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print events as JSON lines",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			filter := containers.EventFilter{
+				User:    c.String("user"),
+				Project: c.String("project"),
+				Types:   c.StringSlice("type"),
+				Follow:  c.Bool("follow"),
+			}
+			if c.IsSet("since") {
+				if filter.Since, err = parseAuditTime(c.String("since"), time.Now()); err != nil {
+					return err
+				}
+			}
+			if !filter.Follow && filter.Since.IsZero() {
+				return fmt.Errorf("nothing to print, use --follow or --since")
+			}
+
+			events, errs := manager.Events(c.Context, filter)
+			enc := json.NewEncoder(c.App.Writer)
+			for event := range events {
+				if c.Bool("json") {
+					if err := enc.Encode(event); err != nil {
+						return err
+					}
+					continue
+				}
+				fmt.Fprintln(c.App.Writer, formatEvent(event))
+			}
+			return <-errs
+		},
+	}
+}
+
+func formatEvent(event containers.Event) string {
+	line := fmt.Sprintf("%s %s %s/%s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Type, event.User, event.Project)
+	if event.Container != "" {
+		line += " " + event.Container
+	}
+	if event.ExitCode != nil {
+		line += fmt.Sprintf(" (exit code %d)", *event.ExitCode)
+	}
+	if event.Health != "" {
+		line += " (" + event.Health + ")"
+	}
+	return line
+}
+
+// Stream events as server-sent events, filtered with the user, project and type query parameters
+func eventsHandler(manager *containers.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		query := r.URL.Query()
+		filter := containers.EventFilter{
+			User:    query.Get("user"),
+			Project: query.Get("project"),
+			Follow:  true,
+		}
+		for _, types := range query["type"] {
+			filter.Types = append(filter.Types, strings.Split(types, ",")...)
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		events, errs := manager.Events(ctx, filter)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// comments keep proxies from closing an idle connection
+		keepalive := time.NewTicker(30 * time.Second)
+		defer keepalive.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					if err := <-errs; err != nil {
+						fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+						flusher.Flush()
+					}
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				flusher.Flush()
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
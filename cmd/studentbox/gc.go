@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func gcCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "gc",
+		Usage: "Remove orphaned pods, containers, runtime images, networks and, optionally, data directories",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print orphans, don't remove anything",
+			},
+			&cli.DurationFlag{
+				Name:  "older-than",
+				Usage: "Ignore resources more recent than this, e.g. spawns in progress (0 to include everything)",
+				Value: time.Hour,
+			},
+			&cli.BoolFlag{
+				Name:  "data",
+				Usage: "Also remove data directories of projects without pod",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print orphans as JSON",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			orphans, err := manager.GarbageCollect(containers.GCOptions{
+				DryRun:     c.Bool("dry-run"),
+				OlderThan:  c.Duration("older-than"),
+				RemoveData: c.Bool("data"),
+			})
+			if err != nil {
+				return err
+			}
+
+			if c.Bool("json") {
+				enc := json.NewEncoder(c.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(orphans)
+			}
+
+			if len(orphans) == 0 {
+				fmt.Fprintln(c.App.Writer, "No orphans")
+				return nil
+			}
+			failed := 0
+			for _, orphan := range orphans {
+				status := "kept"
+				switch {
+				case orphan.Error != "":
+					status = "error: " + orphan.Error
+					failed++
+				case orphan.Removed:
+					status = "removed"
+				case c.Bool("dry-run"):
+					status = "would be removed"
+				case orphan.Kind == containers.OrphanData:
+					status = "kept, use --data to remove"
+				}
+				fmt.Fprintf(c.App.Writer, "- %s %s (%s): %s\n", orphan.Kind, orphan.Name, orphan.Created.Format("2006-01-02 15:04"), status)
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to remove %d orphan(s)", failed)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func projectFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "user",
+			Aliases:  []string{"u"},
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "project",
+			Aliases:  []string{"p"},
+			Required: true,
+		},
+	}
+}
+
+func startCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "start",
+		Usage: "Start a stopped project's runtime",
+		Flags: projectFlags(),
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+			user, project := c.String("user"), c.String("project")
+			if err := manager.StartPod(user, project); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.App.Writer, "Started project %s/%s\n", user, project)
+			return nil
+		},
+	}
+}
+
+func stopCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stop",
+		Usage: "Stop a project's runtime, keeping its containers and data",
+		Flags: projectFlags(),
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+			user, project := c.String("user"), c.String("project")
+			if err := manager.StopPod(user, project); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.App.Writer, "Stopped project %s/%s\n", user, project)
+			return nil
+		},
+	}
+}
+
+func reapCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "reap",
+		Usage: "Stop projects without network activity for longer than a TTL",
+		Description: "Activity is measured by comparing network counters between runs,\n" +
+			"so reap must run periodically, either with --interval or from a timer.\n" +
+			"Reaped projects show \"stopped: idle\" in status and restart with start.",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:    "ttl",
+				Usage:   "Stop projects idle for longer than this",
+				EnvVars: []string{"STUDENTBOX_IDLE_TTL"},
+				Value:   24 * time.Hour,
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Keep running, checking activity at this interval",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print idle projects, don't stop them",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			ttl, interval := c.Duration("ttl"), c.Duration("interval")
+			for {
+				if err := reap(c, manager, ttl); err != nil {
+					if interval == 0 {
+						return err
+					}
+					fmt.Fprintf(c.App.ErrWriter, "Error: %s\n", err)
+				}
+				if interval == 0 {
+					return nil
+				}
+				select {
+				case <-c.Context.Done():
+					return nil
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+}
+
+func reap(c *cli.Context, manager *containers.Manager, ttl time.Duration) error {
+	results, err := manager.ReapIdle(ttl, c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != nil {
+			failed++
+			fmt.Fprintf(c.App.Writer, "- %s/%s: error: %s\n", result.User, result.Project, result.Error)
+			continue
+		}
+		if !result.Idle {
+			continue
+		}
+		status := "stopped"
+		if !result.Stopped {
+			status = "would be stopped"
+		}
+		fmt.Fprintf(c.App.Writer, "- %s/%s: idle since %s, %s\n", result.User, result.Project, result.LastActivity.Format("2006-01-02 15:04"), status)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to reap %d project(s)", failed)
+	}
+	return nil
+}
+
+// Parse an expiry date: a duration from now (e.g. 720h), a date (end of that day, local time) or RFC 3339
+func parseExpires(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected a duration (720h), a date (2006-01-02) or RFC 3339", value)
+}
+
+// Human-readable time left before expiry
+func remaining(expires, now time.Time) string {
+	if expires.IsZero() {
+		return "never expires"
+	}
+	left := expires.Sub(now)
+	if left <= 0 {
+		return "expired"
+	}
+	if left >= 48*time.Hour {
+		return fmt.Sprintf("expires in %dd", int(left.Hours()/24))
+	}
+	return "expires in " + left.Truncate(time.Minute).String()
+}
+
+func extendCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "extend",
+		Usage: "Change a project's expiry date",
+		Flags: append(projectFlags(),
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "New expiry date: a duration from now (720h), a date (2006-01-02) or RFC 3339",
+			},
+			&cli.DurationFlag{
+				Name:  "by",
+				Usage: "Push the current expiry date back by this duration",
+			},
+			&cli.BoolFlag{
+				Name:  "never",
+				Usage: "Remove the expiry date",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+			user, project := c.String("user"), c.String("project")
+
+			var expires time.Time
+			switch {
+			case c.Bool("never"):
+			case c.IsSet("until"):
+				if expires, err = parseExpires(c.String("until"), time.Now()); err != nil {
+					return err
+				}
+			case c.IsSet("by"):
+				current, err := manager.GetExpiry(user, project)
+				if err != nil {
+					return err
+				}
+				if current.IsZero() {
+					return fmt.Errorf("project %s/%s doesn't expire, use --until", user, project)
+				}
+				expires = current.Add(c.Duration("by"))
+			default:
+				return fmt.Errorf("one of --until, --by or --never is required")
+			}
+
+			if err := manager.Extend(user, project, expires); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.App.Writer, "Project %s/%s %s\n", user, project, remaining(expires, time.Now()))
+			return nil
+		},
+	}
+}
+
+func expireCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "expire",
+		Usage: "Warn about projects close to their expiry date, stop expired ones",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{
+				Name:  "grace",
+				Usage: "Wait this long after the expiry date before stopping",
+			},
+			&cli.DurationFlag{
+				Name:  "warn-before",
+				Usage: "Warn this long before the expiry date",
+				Value: 72 * time.Hour,
+			},
+			&cli.StringFlag{
+				Name:  "warn-hook",
+				Usage: "Shell command run to warn, with STUDENTBOX_USER, STUDENTBOX_PROJECT and STUDENTBOX_EXPIRES set",
+			},
+			&cli.StringFlag{
+				Name:  "snapshot-dir",
+				Usage: "Archive expired projects data to this directory",
+			},
+			&cli.BoolFlag{
+				Name:  "destroy",
+				Usage: "Remove expired pods after stopping them",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Keep running, checking expiry dates at this interval",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only print projects to warn or expire",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			opt := containers.ExpireOptions{
+				Grace:       c.Duration("grace"),
+				WarnBefore:  c.Duration("warn-before"),
+				SnapshotDir: c.String("snapshot-dir"),
+				Destroy:     c.Bool("destroy"),
+				DryRun:      c.Bool("dry-run"),
+			}
+			if hook := c.String("warn-hook"); hook != "" {
+				opt.Warn = func(user, project string, expires time.Time) error {
+					cmd := exec.CommandContext(c.Context, "sh", "-c", hook)
+					cmd.Env = append(os.Environ(),
+						"STUDENTBOX_USER="+user,
+						"STUDENTBOX_PROJECT="+project,
+						"STUDENTBOX_EXPIRES="+expires.Format(time.RFC3339),
+					)
+					cmd.Stdout, cmd.Stderr = c.App.Writer, c.App.ErrWriter
+					return cmd.Run()
+				}
+			}
+
+			interval := c.Duration("interval")
+			for {
+				if err := expire(c, manager, opt); err != nil {
+					if interval == 0 {
+						return err
+					}
+					fmt.Fprintf(c.App.ErrWriter, "Error: %s\n", err)
+				}
+				if interval == 0 {
+					return nil
+				}
+				select {
+				case <-c.Context.Done():
+					return nil
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+}
+
+func expire(c *cli.Context, manager *containers.Manager, opt containers.ExpireOptions) error {
+	results, err := manager.ExpirePods(opt)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		var status string
+		switch {
+		case result.Error != "":
+			failed++
+			status = "error: " + result.Error
+		case opt.DryRun && result.Expired:
+			status = "would be expired"
+		case opt.DryRun:
+			status = "would be warned"
+		case result.Destroyed:
+			status = "destroyed"
+		case result.Expired:
+			status = "stopped"
+		default:
+			status = "warned"
+		}
+		if result.Snapshot != "" {
+			status += ", snapshot in " + result.Snapshot
+		}
+		fmt.Fprintf(c.App.Writer, "- %s/%s (expires %s): %s\n", result.User, result.Project, result.Expires.Format("2006-01-02 15:04"), status)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to expire %d project(s)", failed)
+	}
+	return nil
+}
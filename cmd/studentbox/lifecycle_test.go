@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpires(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Time
+		valid    bool
+	}{
+		{"duration", "720h", now.Add(720 * time.Hour), true},
+		{"end of day local time", "2023-06-01", time.Date(2023, 6, 1, 23, 59, 59, 0, time.Local), true},
+		{"RFC 3339", "2023-06-01T08:00:00+02:00", time.Date(2023, 6, 1, 6, 0, 0, 0, time.UTC), true},
+		{"days", "30d", time.Time{}, false},
+		{"empty", "", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpires(tt.value, now)
+			if !tt.valid {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		expires  time.Time
+		expected string
+	}{
+		{"never", time.Time{}, "never expires"},
+		{"past", now.Add(-time.Minute), "expired"},
+		{"now", now, "expired"},
+		{"hours", now.Add(5*time.Hour + 30*time.Minute + 20*time.Second), "expires in 5h30m0s"},
+		{"just under two days", now.Add(47 * time.Hour), "expires in 47h0m0s"},
+		{"days", now.Add(75 * time.Hour), "expires in 3d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remaining(tt.expires, now); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -1,24 +1,37 @@
 package main
 
 import (
-	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"golang.org/x/exp/slog"
 
 	"github.com/sinux-l5d/studentbox/internal/containers"
 	"github.com/sinux-l5d/studentbox/internal/runtimes"
+	"github.com/sinux-l5d/studentbox/internal/webhook"
 )
 
 var (
 	// global flags
-	socket   string
-	hostPath string
-	version  = "dev"
+	socket        string
+	hostPath      string
+	concurrency   int
+	allowedImages cli.StringSlice
+	signatureKey  string
+	mirrors       cli.StringSlice
+	authFile      string
+	logLevel      string
+	logFormat     string
+	principal     string
+	auditLog      string
+	auditSyslog   bool
+	webhooksFile  string
+	network       containers.NetworkOptions
+	version       = "dev"
 )
 
 func newManager(w io.Writer) (*containers.Manager, error) {
@@ -27,15 +40,71 @@ func newManager(w io.Writer) (*containers.Manager, error) {
 	if hostPath != "" {
 		opt.HostPath = hostPath
 	}
-	// get abs current dir
-	if w == nil {
-		opt.Logger = log.New(io.Discard, "", log.Flags())
-	} else {
-		opt.Logger = log.New(w, "[containers] ", log.Flags())
+	opt.Concurrency = concurrency
+	opt.AllowedImages = allowedImages.Value()
+	opt.SignatureKey = signatureKey
+	opt.AuthFile = authFile
+	opt.Principal = principal
+	opt.AuditLog = auditLog
+	opt.AuditSyslog = auditSyslog
+	opt.Network = network
+	if webhooksFile != "" {
+		hooks, err := webhook.LoadHooks(webhooksFile)
+		if err != nil {
+			return nil, err
+		}
+		opt.Webhooks = hooks
 	}
+	opt.Mirrors = make(map[string]string)
+	for _, mirror := range mirrors.Value() {
+		from, to, found := strings.Cut(mirror, "=")
+		if !found || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid mirror %s, expected <prefix>=<replacement>", mirror)
+		}
+		opt.Mirrors[from] = to
+	}
+	logger, err := newLogger(w)
+	if err != nil {
+		return nil, err
+	}
+	opt.Logger = logger
 	return containers.NewManager(opt)
 }
 
+// Logger writing to w with the level and format of the global flags
+func newLogger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", logLevel)
+	}
+	options := &slog.HandlerOptions{Level: level}
+	switch logFormat {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, expected text or json", logFormat)
+}
+
+func pullFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "pull",
+		Usage: "Pull policy for runtime images: missing, always, never or newer",
+		Value: containers.PullMissing,
+		Action: func(_ *cli.Context, v string) error {
+			return containers.ValidatePullPolicy(v)
+		},
+	}
+}
+
+func removePulledFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "remove-pulled",
+		Usage: "Remove images pulled by spawn if it fails",
+	}
+}
+
 // func (app *cli.App) Printf(format string, a ...any) (int, error) {
 // 	return fmt.Fprintf(app.Writer, format+"\n", a...)
 // }
@@ -66,6 +135,93 @@ func main() {
 				EnvVars:     []string{"HOSTPATH"},
 				Destination: &hostPath,
 			},
+			&cli.IntFlag{
+				Name:        "concurrency",
+				Usage:       "Maximum number of concurrent image pulls and container creations",
+				Value:       containers.DefaultManagerOptions().Concurrency,
+				Destination: &concurrency,
+			},
+			&cli.StringSliceFlag{
+				Name:        "allowed-image",
+				Usage:       "Registry or image name prefix allowed to be pulled, can be repeated (default: all)",
+				EnvVars:     []string{"STUDENTBOX_ALLOWED_IMAGES"},
+				Destination: &allowedImages,
+			},
+			&cli.PathFlag{
+				Name:        "signature-key",
+				Usage:       "Public key (e.g. cosign.pub) runtime images must be signed with",
+				EnvVars:     []string{"STUDENTBOX_SIGNATURE_KEY"},
+				Destination: &signatureKey,
+			},
+			&cli.StringSliceFlag{
+				Name:        "mirror",
+				Usage:       "Pull runtime images from a mirror, can be repeated (e.g. ghcr.io/sinux-l5d/studentbox/runtime=registry.example.edu/studentbox)",
+				EnvVars:     []string{"STUDENTBOX_MIRRORS"},
+				Destination: &mirrors,
+			},
+			&cli.PathFlag{
+				Name:        "authfile",
+				Usage:       "Registry credentials file used to pull images",
+				EnvVars:     []string{"REGISTRY_AUTH_FILE"},
+				Destination: &authFile,
+			},
+			&cli.StringFlag{
+				Name:        "log-level",
+				Usage:       "Minimum level of logs written on stderr: debug, info, warn or error",
+				EnvVars:     []string{"STUDENTBOX_LOG_LEVEL"},
+				Value:       "info",
+				Destination: &logLevel,
+			},
+			&cli.StringFlag{
+				Name:        "log-format",
+				Usage:       "Format of logs: text or json",
+				EnvVars:     []string{"STUDENTBOX_LOG_FORMAT"},
+				Value:       "text",
+				Destination: &logFormat,
+			},
+			&cli.StringFlag{
+				Name:        "principal",
+				Usage:       "Who is operating, recorded in the audit log (default: $SUDO_USER or $USER)",
+				EnvVars:     []string{"STUDENTBOX_PRINCIPAL", "SUDO_USER", "USER"},
+				Destination: &principal,
+			},
+			&cli.PathFlag{
+				Name:        "audit-log",
+				Usage:       "Audit log file (default: .audit.jsonl in the data directory)",
+				EnvVars:     []string{"STUDENTBOX_AUDIT_LOG"},
+				Destination: &auditLog,
+			},
+			&cli.BoolFlag{
+				Name:        "audit-syslog",
+				Usage:       "Also send audit entries to syslog",
+				EnvVars:     []string{"STUDENTBOX_AUDIT_SYSLOG"},
+				Destination: &auditSyslog,
+			},
+			&cli.PathFlag{
+				Name:        "webhooks",
+				Usage:       "JSON file of webhooks notified of lifecycle events, see README",
+				EnvVars:     []string{"STUDENTBOX_WEBHOOKS"},
+				Destination: &webhooksFile,
+			},
+			&cli.StringFlag{
+				Name:        "network",
+				Usage:       "Network of spawned pods: shared (podman's default), user (one per user) or project (one per project)",
+				EnvVars:     []string{"STUDENTBOX_NETWORK"},
+				Value:       containers.NetworkShared,
+				Destination: &network.Scope,
+			},
+			&cli.BoolFlag{
+				Name:        "network-isolate",
+				Usage:       "Block traffic between user or project networks",
+				EnvVars:     []string{"STUDENTBOX_NETWORK_ISOLATE"},
+				Destination: &network.Isolate,
+			},
+			&cli.BoolFlag{
+				Name:        "network-no-internet",
+				Usage:       "Block outbound traffic from user or project networks",
+				EnvVars:     []string{"STUDENTBOX_NETWORK_NO_INTERNET"},
+				Destination: &network.NoInternet,
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -73,7 +229,7 @@ func main() {
 				Aliases: []string{"ls"},
 				Usage:   "List containers belonging to Studentbox",
 				Action: func(c *cli.Context) error {
-					manager, err := newManager(nil)
+					manager, err := newManager(c.App.ErrWriter)
 					if err != nil {
 						return err
 					}
@@ -88,66 +244,34 @@ func main() {
 						return nil
 					}
 
+					now := time.Now()
+					lifetimes := make(map[string]string)
 					fmt.Fprintln(c.App.Writer, "Containers:")
 					for _, container := range containers {
-						ip, port, err := container.GetPort()
-						if err != nil || (ip == "" && port == "") {
-							fmt.Fprintf(c.App.Writer, "- (%s/%s) %s \n", container.User, container.Project, container.Name)
-						} else {
-							fmt.Fprintf(c.App.Writer, "- (%s/%s) %s -> %s:%s\n", container.User, container.Project, container.Name, ip, port)
+						key := container.User + "/" + container.Project
+						lifetime, ok := lifetimes[key]
+						if !ok {
+							expires, err := manager.GetExpiry(container.User, container.Project)
+							if err != nil {
+								lifetime = "unknown expiry"
+							} else {
+								lifetime = remaining(expires, now)
+							}
+							lifetimes[key] = lifetime
 						}
-					}
 
-					return nil
-				},
-			},
-			{
-				Name:  "status",
-				Usage: "Print status of a project's runtime",
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:     "user",
-						Aliases:  []string{"u"},
-						Required: true,
-					},
-					&cli.StringFlag{
-						Name:     "project",
-						Aliases:  []string{"p"},
-						Required: true,
-					},
-				},
-				Action: func(c *cli.Context) error {
-					manager, err := newManager(nil)
-					if err != nil {
-						return err
-					}
-					user, project := c.String("user"), c.String("project")
-					cntnrs, err := manager.GetContainers(user, project)
-					if err != nil {
-						if errors.Is(err, &containers.ErrContainerDontExists{}) {
-							fmt.Fprintf(c.App.ErrWriter, "container for user %s, project %s doesn't exist\n", user, project)
+						ip, port, err := container.GetPort()
+						if err != nil || (ip == "" && port == "") {
+							fmt.Fprintf(c.App.Writer, "- (%s/%s) %s [%s]\n", container.User, container.Project, container.Name, lifetime)
 						} else {
-							return err
-						}
-					}
-
-					status := make(map[string]string)
-					for _, container := range cntnrs {
-						s, err := container.Status()
-						if err != nil {
-							return err
+							fmt.Fprintf(c.App.Writer, "- (%s/%s) %s -> %s:%s [%s]\n", container.User, container.Project, container.Name, ip, port, lifetime)
 						}
-						status[container.Name] = s
-					}
-
-					fmt.Fprintf(c.App.Writer, "Status of project %s/%s:\n", user, project)
-					for name, s := range status {
-						fmt.Fprintf(c.App.Writer, "- %s: %s\n", name, s)
 					}
 
 					return nil
 				},
 			},
+			statusCommand(),
 			{
 				Name:  "spawn",
 				Usage: "Spawn a runtime (pod of container) for a project",
@@ -170,19 +294,79 @@ func main() {
 					&cli.StringSliceFlag{
 						Name:    "env",
 						Aliases: []string{"e"},
-						Usage:   "Set environment variables (e.g. -e FOO=bar)",
+						Usage:   "Set environment variables, optionally for a single container (e.g. -e FOO=bar, -e mysql:FOO=bar)",
 						Action: func(_ *cli.Context, v []string) error {
 							for _, value := range v {
-								if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") || strings.HasSuffix(value, "=") {
-									return fmt.Errorf("invalid environment variable %s", value)
+								if err := runtimes.NewInputEnvVars().Parse(value); err != nil {
+									return err
 								}
 							}
 							return nil
 						},
 					},
+					&cli.StringSliceFlag{
+						Name:  "env-file",
+						Usage: "Read environment variables from a dotenv file, overridden by -e (e.g. --env-file vars.env)",
+					},
+					&cli.BoolFlag{
+						Name:  "prompt",
+						Usage: "Interactively ask for runtime's environment variables without default value",
+					},
+					pullFlag(),
+					removePulledFlag(),
+					&cli.BoolFlag{
+						Name:  "local",
+						Usage: "Use images built with \"runtimes build\" instead of pulling them",
+					},
+					&cli.BoolFlag{
+						Name:    "quiet",
+						Aliases: []string{"q"},
+						Usage:   "Don't print image pull progress",
+					},
+					&cli.BoolFlag{
+						Name:  "wait",
+						Usage: "Wait until every container is healthy and the published port answers HTTP",
+					},
+					&cli.DurationFlag{
+						Name:  "wait-timeout",
+						Usage: "Maximum time to wait with --wait",
+						Value: 2 * time.Minute,
+					},
+					&cli.StringFlag{
+						Name:  "restart",
+						Usage: "Restart policy of all containers: no, on-failure[:N] or always (default: the runtime's)",
+						Action: func(_ *cli.Context, v string) error {
+							_, err := runtimes.ParseRestartPolicy(v)
+							return err
+						},
+					},
+					&cli.StringFlag{
+						Name:  "network",
+						Usage: "Network of the pod: shared, user or project (default: the global --network)",
+					},
+					&cli.BoolFlag{
+						Name:  "isolate",
+						Usage: "Block traffic between the pod's network and other isolated networks",
+					},
+					&cli.BoolFlag{
+						Name:  "no-internet",
+						Usage: "Block outbound traffic from the pod's network (e.g. for exams)",
+					},
+					&cli.BoolFlag{
+						Name:  "shared",
+						Usage: "Run images the runtime can share per user (e.g. lamp's mysql) once for all the user's projects",
+					},
+					&cli.StringFlag{
+						Name:  "expires",
+						Usage: "Stop the project after this date: a duration (720h), a date (2006-01-02) or RFC 3339, see expire",
+						Action: func(_ *cli.Context, v string) error {
+							_, err := parseExpires(v, time.Now())
+							return err
+						},
+					},
 				},
 				Action: func(c *cli.Context) error {
-					manager, err := newManager(c.App.Writer)
+					manager, err := newManager(c.App.ErrWriter)
 					if err != nil {
 						return err
 					}
@@ -192,20 +376,34 @@ func main() {
 						return fmt.Errorf("runtime %s doesn't exist", c.String("runtime"))
 					}
 
-					envvar := make(map[string]string, len(c.StringSlice("env")))
+					envvar := runtimes.NewInputEnvVars()
+					for _, path := range c.StringSlice("env-file") {
+						if err := parseEnvFile(envvar, path); err != nil {
+							return err
+						}
+					}
 					for _, value := range c.StringSlice("env") {
-						split := strings.SplitN(value, "=", 2)
-						if len(split) != 2 {
-							return fmt.Errorf("invalid environment variable %s", value)
+						if err := envvar.Parse(value); err != nil {
+							return err
+						}
+					}
+					if c.Bool("prompt") {
+						if err := promptEnvVars(c, runtime, envvar); err != nil {
+							return err
 						}
-						envvar[split[0]] = split[1]
 					}
 
 					opt := containers.PodOptions{
-						User:         c.String("user"),
-						Project:      c.String("project"),
-						InputEnvVars: envvar,
-						Runtime:      runtime,
+						User:               c.String("user"),
+						Project:            c.String("project"),
+						InputEnvVars:       envvar.Global,
+						ImageEnvVars:       envvar.PerImage,
+						Runtime:            runtime,
+						PullPolicy:         c.String("pull"),
+						LocalImages:        c.Bool("local"),
+						RemovePulledImages: c.Bool("remove-pulled"),
+						RestartPolicy:      c.String("restart"),
+						SharedServices:     c.Bool("shared"),
 						// Runtime: runtimes.Runtime{
 						// 	Name: "dummy",
 						// 	Images: map[string]runtimes.Image{
@@ -219,7 +417,38 @@ func main() {
 						// 	},
 						// },
 					}
-					return manager.SpawnPod(&opt)
+					if c.IsSet("expires") {
+						if opt.Expires, err = parseExpires(c.String("expires"), time.Now()); err != nil {
+							return err
+						}
+					}
+					if c.IsSet("network") || c.IsSet("isolate") || c.IsSet("no-internet") {
+						podNetwork := network
+						if c.IsSet("network") {
+							podNetwork.Scope = c.String("network")
+						}
+						if c.IsSet("isolate") {
+							podNetwork.Isolate = c.Bool("isolate")
+						}
+						if c.IsSet("no-internet") {
+							podNetwork.NoInternet = c.Bool("no-internet")
+						}
+						opt.Network = &podNetwork
+					}
+					if !c.Bool("quiet") {
+						opt.PullProgress = c.App.ErrWriter
+					}
+					if err := manager.SpawnPod(&opt); err != nil {
+						return err
+					}
+
+					if c.Bool("wait") {
+						if err := manager.WaitReady(opt.User, opt.Project, c.Duration("wait-timeout")); err != nil {
+							return err
+						}
+						fmt.Fprintf(c.App.Writer, "Project %s/%s is ready\n", opt.User, opt.Project)
+					}
+					return nil
 				},
 			},
 			{
@@ -250,7 +479,7 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					manager, err := newManager(c.App.Writer)
+					manager, err := newManager(c.App.ErrWriter)
 					if err != nil {
 						return err
 					}
@@ -300,6 +529,20 @@ func main() {
 					return nil
 				},
 			},
+			envCommand(),
+			bulkCommand(),
+			runtimesCommand(),
+			gcCommand(),
+			startCommand(),
+			stopCommand(),
+			reapCommand(),
+			extendCommand(),
+			expireCommand(),
+			statsCommand(),
+			metricsExporterCommand(),
+			auditCommand(),
+			eventsCommand(),
+			serveCommand(),
 		},
 	}
 
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+	"github.com/sinux-l5d/studentbox/internal/metrics"
+)
+
+func metricsExporterCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "metrics-exporter",
+		Usage: "Serve Prometheus metrics about projects",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "listen",
+				Usage:   "Address to listen on",
+				EnvVars: []string{"STUDENTBOX_METRICS_LISTEN"},
+				Value:   ":9850",
+			},
+			&cli.StringFlag{
+				Name:  "path",
+				Usage: "URL path of the metrics",
+				Value: "/metrics",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle(c.String("path"), metricsHandler(manager))
+			return listenAndServe(c, mux)
+		},
+	}
+}
+
+func metricsHandler(manager *containers.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		families, err := manager.Collect()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// buffer so a write error doesn't send a partial scrape with status 200
+		var body bytes.Buffer
+		if err := metrics.Write(&body, families); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		body.WriteTo(w)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+func parseEnvFile(envvar *runtimes.InputEnvVars, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := envvar.ParseFile(f); err != nil {
+		return fmt.Errorf("failed to parse env file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Ask for each env var of the runtime that has no default value and wasn't given already
+// Input is masked for env vars using the password modifier, which also allows empty input
+func promptEnvVars(c *cli.Context, runtime runtimes.Runtime, envvar *runtimes.InputEnvVars) error {
+	shortNames := make([]string, 0, len(runtime.Images))
+	for name := range runtime.Images {
+		shortNames = append(shortNames, name)
+	}
+	sort.Strings(shortNames)
+
+	reader := bufio.NewReader(os.Stdin)
+	for _, shortName := range shortNames {
+		for _, env := range runtime.Images[shortName].EnvVars {
+			if env.DefaultValue != "" || envvar.Has(shortName, env.Name) {
+				continue
+			}
+
+			isPassword := env.HasModifier("password")
+			if isPassword {
+				fmt.Fprintf(c.App.Writer, "%s:%s (empty to generate): ", shortName, env.Name)
+			} else {
+				fmt.Fprintf(c.App.Writer, "%s:%s: ", shortName, env.Name)
+			}
+
+			var value string
+			if isPassword && term.IsTerminal(int(os.Stdin.Fd())) {
+				b, err := term.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Fprintln(c.App.Writer)
+				if err != nil {
+					return err
+				}
+				value = string(b)
+			} else {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				value = strings.TrimRight(line, "\r\n")
+			}
+
+			if value != "" {
+				envvar.Set(shortName, env.Name, value)
+			}
+		}
+	}
+	return nil
+}
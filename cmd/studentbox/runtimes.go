@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Subcommands to work with runtimes definitions
+func runtimesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "runtimes",
+		Usage: "Work with runtimes definitions",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "build",
+				Usage:     "Build a runtime's images locally, to be used with spawn --local",
+				ArgsUsage: "<runtime>",
+				Flags: []cli.Flag{
+					&cli.PathFlag{
+						Name:  "dir",
+						Usage: "Directory containing runtimes directories",
+						Value: "runtimes",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() != 1 {
+						return fmt.Errorf("expected a runtime name")
+					}
+
+					manager, err := newManager(c.App.ErrWriter)
+					if err != nil {
+						return err
+					}
+
+					built, err := manager.BuildRuntime(filepath.Join(c.Path("dir"), c.Args().First()), c.App.ErrWriter)
+					if err != nil {
+						return err
+					}
+					for _, reference := range built {
+						fmt.Fprintln(c.App.Writer, reference)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func serveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "Serve Prometheus metrics on /metrics and server-sent events on /events, forward events to webhooks",
+		Description: "Events can be filtered with query parameters, e.g.\n" +
+			"/events?user=alice&type=container-died,health-changed",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "listen",
+				Usage:   "Address to listen on",
+				EnvVars: []string{"STUDENTBOX_LISTEN"},
+				Value:   ":9850",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			// webhooks for container crashes and health changes need a long-running process
+			go func() {
+				if err := manager.ForwardEvents(c.Context); err != nil {
+					fmt.Fprintf(c.App.ErrWriter, "Error: failed to forward events to webhooks: %s\n", err)
+				}
+			}()
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metricsHandler(manager))
+			mux.Handle("/events", eventsHandler(manager))
+			return listenAndServe(c, mux)
+		},
+	}
+}
+
+// Serve until the CLI context is done
+func listenAndServe(c *cli.Context, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              c.String("listen"),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-c.Context.Done()
+		server.Close()
+	}()
+
+	fmt.Fprintf(c.App.ErrWriter, "Listening on %s\n", c.String("listen"))
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+// Orders for stats --sort, descending except name
+var statsOrders = map[string]func(a, b *containers.ContainerStats) bool{
+	"name": nil,
+	"cpu":  func(a, b *containers.ContainerStats) bool { return a.CPU > b.CPU },
+	"mem":  func(a, b *containers.ContainerStats) bool { return a.MemUsage > b.MemUsage },
+	"net":  func(a, b *containers.ContainerStats) bool { return a.NetInput+a.NetOutput > b.NetInput+b.NetOutput },
+	"block": func(a, b *containers.ContainerStats) bool {
+		return a.BlockInput+a.BlockOutput > b.BlockInput+b.BlockOutput
+	},
+}
+
+func statsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "stats",
+		Usage: "Print resource usage of running projects",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "user",
+				Aliases: []string{"u"},
+				Usage:   "Only projects of this user",
+			},
+			&cli.StringFlag{
+				Name:    "project",
+				Aliases: []string{"p"},
+				Usage:   "Only projects with this name",
+			},
+			&cli.StringFlag{
+				Name:  "sort",
+				Usage: "Sort by name, cpu, mem, net or block",
+				Value: "name",
+				Action: func(_ *cli.Context, v string) error {
+					if _, exists := statsOrders[v]; !exists {
+						return fmt.Errorf("invalid sort %q, expected name, cpu, mem, net or block", v)
+					}
+					return nil
+				},
+			},
+			&cli.BoolFlag{
+				Name:  "containers",
+				Usage: "Also print usage of each container",
+			},
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "Refresh continuously",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Refresh interval with --watch",
+				Value: 2 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print stats as JSON",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+
+			for {
+				stats, err := manager.Stats(c.String("user"), c.String("project"))
+				if err != nil {
+					return err
+				}
+				sortStats(stats, statsOrders[c.String("sort")])
+
+				if c.Bool("watch") && !c.Bool("json") {
+					// clear the terminal
+					fmt.Fprint(c.App.Writer, "\033[H\033[2J")
+				}
+				if err := printStats(c, stats); err != nil {
+					return err
+				}
+				if !c.Bool("watch") {
+					return nil
+				}
+				select {
+				case <-c.Context.Done():
+					return nil
+				case <-time.After(c.Duration("interval")):
+				}
+			}
+		},
+	}
+}
+
+func sortStats(stats []containers.PodStats, less func(a, b *containers.ContainerStats) bool) {
+	if less == nil {
+		// Manager.Stats already sorts by name
+		return
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return less(&stats[i].ContainerStats, &stats[j].ContainerStats)
+	})
+	for _, pod := range stats {
+		sort.SliceStable(pod.Containers, func(i, j int) bool {
+			return less(&pod.Containers[i], &pod.Containers[j])
+		})
+	}
+}
+
+func printStats(c *cli.Context, stats []containers.PodStats) error {
+	if c.Bool("json") {
+		return json.NewEncoder(c.App.Writer).Encode(stats)
+	}
+	if len(stats) == 0 {
+		fmt.Fprintln(c.App.Writer, "No running projects")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCPU %\tMEM USAGE / LIMIT\tNET IO\tBLOCK IO\tPIDS")
+	row := func(name string, s *containers.ContainerStats) {
+		fmt.Fprintf(w, "%s\t%.2f%%\t%s / %s\t%s / %s\t%s / %s\t%d\n", name, s.CPU,
+			humanBytes(s.MemUsage), humanBytes(s.MemLimit),
+			humanBytes(s.NetInput), humanBytes(s.NetOutput),
+			humanBytes(s.BlockInput), humanBytes(s.BlockOutput),
+			s.PIDs)
+	}
+	for i := range stats {
+		row(stats[i].User+"/"+stats[i].Project, &stats[i].ContainerStats)
+		if c.Bool("containers") {
+			for j := range stats[i].Containers {
+				row("  "+stats[i].Containers[j].Name, &stats[i].Containers[j])
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// Format a byte count with a binary unit (e.g. 1.5MiB)
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func TestSortStats(t *testing.T) {
+	pod := func(project string, cpu float64, mem uint64, containerCPUs ...float64) containers.PodStats {
+		s := containers.PodStats{User: "alice", Project: project}
+		s.CPU, s.MemUsage = cpu, mem
+		for i, c := range containerCPUs {
+			s.Containers = append(s.Containers, containers.ContainerStats{Name: string(rune('a' + i)), CPU: c})
+		}
+		return s
+	}
+	tests := []struct {
+		name     string
+		order    string
+		expected string
+	}{
+		{"name keeps order", "name", "api blog cms"},
+		{"cpu", "cpu", "blog cms api"},
+		{"mem", "mem", "cms api blog"},
+		{"net ties keep order", "net", "api blog cms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := []containers.PodStats{
+				pod("api", 1, 200, 0.5, 0.5),
+				pod("blog", 50, 100, 10, 40),
+				pod("cms", 20, 300),
+			}
+			sortStats(stats, statsOrders[tt.order])
+			got := ""
+			for i, s := range stats {
+				if i > 0 {
+					got += " "
+				}
+				got += s.Project
+			}
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+			if tt.order == "cpu" && stats[0].Containers[0].Name != "b" {
+				t.Errorf("expected containers sorted by cpu, got %+v", stats[0].Containers)
+			}
+		})
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	tests := []struct {
+		n        uint64
+		expected string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1536, "1.5KiB"},
+		{1024*1024 - 1, "1024.0KiB"},
+		{5 * 1024 * 1024 * 1024, "5.0GiB"},
+		{1 << 62, "4.0EiB"},
+	}
+	for _, tt := range tests {
+		if got := humanBytes(tt.n); got != tt.expected {
+			t.Errorf("humanBytes(%d): expected %s, got %s", tt.n, tt.expected, got)
+		}
+	}
+}
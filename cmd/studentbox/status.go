@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "Print status of a project's runtime",
+		Flags: append(projectFlags(),
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print containers information as JSON",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			manager, err := newManager(c.App.ErrWriter)
+			if err != nil {
+				return err
+			}
+			user, project := c.String("user"), c.String("project")
+			cntnrs, err := manager.GetContainers(user, project)
+			if err != nil {
+				if errors.Is(err, &containers.ErrContainerDontExists{}) {
+					fmt.Fprintf(c.App.ErrWriter, "container for user %s, project %s doesn't exist\n", user, project)
+				} else {
+					return err
+				}
+			}
+
+			infos := make([]*containers.ContainerInfo, 0, len(cntnrs))
+			for _, container := range cntnrs {
+				info, err := container.Inspect()
+				if err != nil {
+					return err
+				}
+				infos = append(infos, info)
+			}
+			sort.Slice(infos, func(i, j int) bool {
+				return infos[i].Name < infos[j].Name
+			})
+
+			if c.Bool("json") {
+				enc := json.NewEncoder(c.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(infos)
+			}
+
+			fmt.Fprintf(c.App.Writer, "Status of project %s/%s:\n", user, project)
+			state, err := manager.GetState(user, project)
+			if err != nil {
+				return err
+			}
+			if state.StopReason != "" {
+				fmt.Fprintf(c.App.Writer, "stopped: %s (since %s)\n", state.StopReason, state.StoppedAt.Format("2006-01-02 15:04"))
+			}
+			printStatus(c, infos, time.Now())
+			return nil
+		},
+	}
+}
+
+func printStatus(c *cli.Context, infos []*containers.ContainerInfo, now time.Time) {
+	url := ""
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tSTATE\tHEALTH\tUPTIME\tEXIT\tRESTARTS\tLIMITS\tIMAGE")
+	for _, info := range infos {
+		uptime, exit := "-", "-"
+		if info.Status == "running" {
+			uptime = now.Sub(info.StartedAt).Truncate(time.Second).String()
+		} else if !info.FinishedAt.IsZero() {
+			exit = fmt.Sprint(info.ExitCode)
+			if info.OOMKilled {
+				exit += " (OOM)"
+			}
+		}
+		restarts := fmt.Sprint(info.RestartCount)
+		if info.CrashLooping {
+			restarts += " (crash loop)"
+		}
+		health := info.Health
+		if health == "" {
+			health = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.Status, health, uptime, exit, restarts, formatLimits(info.Limits), shortDigest(info.ImageDigest))
+		if info.URL != "" {
+			url = info.URL
+		}
+	}
+	w.Flush()
+
+	for _, info := range infos {
+		if info.Error != "" {
+			fmt.Fprintf(c.App.Writer, "%s last error: %s\n", info.Name, info.Error)
+		}
+	}
+	if url != "" {
+		fmt.Fprintf(c.App.Writer, "URL: %s\n", url)
+	}
+}
+
+func formatLimits(limits containers.Limits) string {
+	parts := make([]string, 0, 3)
+	if limits.MemoryBytes > 0 {
+		parts = append(parts, "mem "+humanBytes(uint64(limits.MemoryBytes)))
+	}
+	if limits.CPUs > 0 {
+		parts = append(parts, fmt.Sprintf("cpu %g", limits.CPUs))
+	}
+	if limits.PIDs > 0 {
+		parts = append(parts, fmt.Sprintf("pids %d", limits.PIDs))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// First 12 hex characters of a digest, like image IDs in podman's output
+func shortDigest(digest string) string {
+	_, hex, found := strings.Cut(digest, ":")
+	if !found {
+		hex = digest
+	}
+	if len(hex) > 12 {
+		hex = hex[:12]
+	}
+	if hex == "" {
+		return "-"
+	}
+	return hex
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/sinux-l5d/studentbox/internal/containers"
+)
+
+func TestFormatLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   containers.Limits
+		expected string
+	}{
+		{"unlimited", containers.Limits{}, "none"},
+		{"memory", containers.Limits{MemoryBytes: 512 * 1024 * 1024}, "mem 512.0MiB"},
+		{"all", containers.Limits{MemoryBytes: 1024 * 1024 * 1024, CPUs: 0.5, PIDs: 100}, "mem 1.0GiB, cpu 0.5, pids 100"},
+		{"cpus only", containers.Limits{CPUs: 2}, "cpu 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatLimits(tt.limits); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	tests := []struct {
+		digest   string
+		expected string
+	}{
+		{"sha256:0123456789abcdef0123", "0123456789ab"},
+		{"0123456789abcdef", "0123456789ab"},
+		{"sha256:abc", "abc"},
+		{"", "-"},
+		{"sha256:", "-"},
+	}
+	for _, tt := range tests {
+		if got := shortDigest(tt.digest); got != tt.expected {
+			t.Errorf("shortDigest(%q): expected %q, got %q", tt.digest, tt.expected, got)
+		}
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	running := &containers.ContainerInfo{
+		Name:        "sb-alice-web-apache",
+		Image:       "ghcr.io/sinux-l5d/studentbox/runtime/lamp.apache:latest",
+		ImageDigest: "sha256:0123456789abcdef",
+		URL:         "http://localhost:8080",
+	}
+	running.Status = "running"
+	running.StartedAt = now.Add(-90 * time.Second)
+	running.Health = "healthy"
+
+	crashed := &containers.ContainerInfo{Name: "sb-alice-web-mysql", Limits: containers.Limits{PIDs: 100}}
+	crashed.Status = "exited"
+	crashed.FinishedAt = now.Add(-time.Minute)
+	crashed.ExitCode = 137
+	crashed.OOMKilled = true
+	crashed.RestartCount = 4
+	crashed.CrashLooping = true
+	crashed.Error = "out of memory"
+
+	var out bytes.Buffer
+	printStatus(cli.NewContext(&cli.App{Writer: &out}, nil, nil), []*containers.ContainerInfo{running, crashed}, now)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected header, 2 containers, an error and the URL, got:\n%s", out.String())
+	}
+	expected := []string{
+		"sb-alice-web-apache running healthy 1m30s - 0 none 0123456789ab",
+		"sb-alice-web-mysql exited - - 137 (OOM) 4 (crash loop) pids 100 -",
+		"sb-alice-web-mysql last error: out of memory",
+		"URL: http://localhost:8080",
+	}
+	for i, line := range lines[1:] {
+		if got := strings.Join(strings.Fields(line), " "); got != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i+1, expected[i], got)
+		}
+	}
+}
@@ -2,7 +2,13 @@
 
 echo "Today is " . date("Y-m-d") . "\n";
 
-$c = mysqli_connect("127.0.0.1:3306", "student", "password", "app");
+// connection details come from the env, see MARIADB_* and, with spawn --shared, STUDENTBOX_MYSQL_HOST
+$host = getenv("STUDENTBOX_MYSQL_HOST") ?: "127.0.0.1";
+$user = getenv("MARIADB_USER") ?: "student";
+$password = getenv("MARIADB_PASSWORD") ?: "password";
+$database = getenv("MARIADB_DATABASE") ?: "app";
+
+$c = mysqli_connect($host . ":3306", $user, $password, $database);
 
 if ($c -> connect_errno) {
   echo "Failed to connect to MySQL: " . $c -> connect_error . "\n";
@@ -0,0 +1,124 @@
+// Append-only log of operations changing projects, answering who did what and when
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Results of an operation
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Who did the operation (e.g. the teacher's login)
+	Principal string `json:"principal"`
+	Action    string `json:"action"`
+	User      string `json:"user,omitempty"`
+	Project   string `json:"project,omitempty"`
+	Runtime   string `json:"runtime,omitempty"`
+	// Names of the env vars set or unset, never their values
+	EnvVars []string `json:"env_vars,omitempty"`
+	// Action-specific information (e.g. stop reason)
+	Detail string `json:"detail,omitempty"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Where entries are written
+type Sink interface {
+	Write(Entry) error
+}
+
+// Append entries as JSON lines to a file
+type FileSink struct {
+	Path string
+}
+
+func (s *FileSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// O_APPEND writes are atomic on local filesystems, the lock covers the others
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Send entries as JSON to the local syslog (or journald) with the auth facility
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink() (*SyslogSink, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "studentbox")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+func (s *SyslogSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if entry.Result == Failure {
+		return s.writer.Warning(string(line))
+	}
+	return s.writer.Info(string(line))
+}
+
+// Criteria to select entries, zero values match everything
+type Filter struct {
+	User    string
+	Project string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f *Filter) Match(entry *Entry) bool {
+	return (f.User == "" || entry.User == f.User) &&
+		(f.Project == "" || entry.Project == f.Project) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || entry.Time.Before(f.Until))
+}
+
+// Read the entries of a JSON lines log matching the filter, in log order
+func Query(r io.Reader, filter Filter) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d: %w", line, err)
+		}
+		if filter.Match(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
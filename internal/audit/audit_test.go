@@ -0,0 +1,62 @@
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sinux-l5d/studentbox/internal/audit"
+)
+
+func TestFileSinkQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink := &audit.FileSink{Path: path}
+
+	day := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := []audit.Entry{
+		{Time: day, Principal: "teacher", Action: "spawn", User: "alice", Project: "web", Runtime: "lamp", EnvVars: []string{"MYSQL_PASSWORD"}, Result: audit.Success},
+		{Time: day.Add(time.Hour), Principal: "teacher", Action: "destroy", User: "alice", Project: "web", Result: audit.Failure, Error: "boom"},
+		{Time: day.Add(24 * time.Hour), Principal: "admin", Action: "spawn", User: "bob", Project: "web", Result: audit.Success},
+	}
+	for _, entry := range entries {
+		if err := sink.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   audit.Filter
+		expected []string
+	}{
+		{"all", audit.Filter{}, []string{"spawn alice", "destroy alice", "spawn bob"}},
+		{"user", audit.Filter{User: "alice"}, []string{"spawn alice", "destroy alice"}},
+		{"project and action", audit.Filter{Project: "web", Action: "spawn"}, []string{"spawn alice", "spawn bob"}},
+		{"since", audit.Filter{Since: day.Add(time.Hour)}, []string{"destroy alice", "spawn bob"}},
+		{"until excluded", audit.Filter{Until: day.Add(time.Hour)}, []string{"spawn alice"}},
+		{"no match", audit.Filter{User: "carol"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			found, err := audit.Query(file, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %d", len(tt.expected), len(found))
+			}
+			for i, entry := range found {
+				if got := entry.Action + " " + entry.User; got != tt.expected[i] {
+					t.Errorf("entry %d: expected %q, got %q", i, tt.expected[i], got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package bulk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// Outcome of an action on a single roster entry
+type Result struct {
+	User    string `json:"user"`
+	Project string `json:"project"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Summary of a bulk action
+type Report struct {
+	Action    string    `json:"action"`
+	DryRun    bool      `json:"dry_run"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Results   []Result  `json:"results"`
+}
+
+// Returned by an action to mark an entry as skipped instead of failed
+type ErrSkipped struct {
+	Reason string
+	// Status recorded instead of StatusSkipped, e.g. StatusSucceeded for entries resumed from a previous report
+	Status string
+}
+
+func (e *ErrSkipped) Error() string {
+	return e.Reason
+}
+
+// Read a report written by a previous run
+func ReadReport(r io.Reader) (*Report, error) {
+	report := &Report{}
+	if err := json.NewDecoder(r).Decode(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Write the report as indented JSON
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Check if the entry succeeded in this report, used to resume after a partial failure
+func (r *Report) HasSucceeded(user, project string) bool {
+	for _, result := range r.Results {
+		if result.User == user && result.Project == project && result.Status == StatusSucceeded {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap an action to skip entries that succeeded in a previous report
+// They are recorded as succeeded again, so the new report can be resumed in turn
+// Nothing is skipped after a dry run, as nothing was done
+func Resume(previous *Report, do func(Entry) error) func(Entry) error {
+	return func(entry Entry) error {
+		if !previous.DryRun && previous.HasSucceeded(entry.User, entry.Project) {
+			return &ErrSkipped{Reason: "succeeded in previous report", Status: StatusSucceeded}
+		}
+		return do(entry)
+	}
+}
+
+// Action of a dry run, printing what would be done to w
+// Entries are recorded as skipped, not succeeded, so resuming from the report runs them all
+func DryRun(w io.Writer, action string) func(Entry) error {
+	var mu sync.Mutex
+	return func(entry Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "would %s %s/%s\n", action, entry.User, entry.Project)
+		return &ErrSkipped{Reason: "dry run"}
+	}
+}
+
+// Run action on every entry with a pool of workers
+// Results are in the same order as entries
+func Run(action string, entries []Entry, workers int, do func(Entry) error) *Report {
+	if workers < 1 {
+		workers = 1
+	}
+
+	report := &Report{
+		Action:  action,
+		Started: time.Now(),
+		Results: make([]Result, len(entries)),
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				entry := entries[i]
+				result := Result{User: entry.User, Project: entry.Project, Status: StatusSucceeded}
+				if err := do(entry); err != nil {
+					result.Status = StatusFailed
+					var skipped *ErrSkipped
+					if errors.As(err, &skipped) {
+						result.Status = StatusSkipped
+						if skipped.Status != "" {
+							result.Status = skipped.Status
+						}
+					}
+					if result.Status != StatusSucceeded {
+						result.Error = err.Error()
+					}
+				}
+				report.Results[i] = result
+			}
+		}()
+	}
+	for i := range entries {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case StatusSucceeded:
+			report.Succeeded++
+		case StatusFailed:
+			report.Failed++
+		case StatusSkipped:
+			report.Skipped++
+		}
+	}
+	report.Finished = time.Now()
+	return report
+}
@@ -0,0 +1,83 @@
+package bulk_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/bulk"
+)
+
+func TestRunResume(t *testing.T) {
+	entries := []bulk.Entry{{User: "a", Project: "p"}, {User: "b", Project: "p"}, {User: "c", Project: "p"}}
+	calls := make(map[string]int)
+	failing := map[string]bool{"b": true, "c": true}
+	do := func(entry bulk.Entry) error {
+		calls[entry.User]++
+		if failing[entry.User] {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	// write and read back each report, like --report then --resume
+	roundTrip := func(report *bulk.Report) *bulk.Report {
+		var buf bytes.Buffer
+		if err := report.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		read, err := bulk.ReadReport(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return read
+	}
+
+	first := roundTrip(bulk.Run("spawn", entries, 1, do))
+	if first.Succeeded != 1 || first.Failed != 2 {
+		t.Fatalf("Unexpected first counts: %+v", first)
+	}
+
+	delete(failing, "b")
+	second := roundTrip(bulk.Run("spawn", entries, 1, bulk.Resume(first, do)))
+	if second.Succeeded != 2 || second.Failed != 1 || second.Skipped != 0 {
+		t.Fatalf("Unexpected second counts: %+v", second)
+	}
+	if second.Results[0].Status != bulk.StatusSucceeded || second.Results[0].Error != "" {
+		t.Errorf("Expected a to be carried forward as succeeded, got %+v", second.Results[0])
+	}
+
+	delete(failing, "c")
+	third := bulk.Run("spawn", entries, 1, bulk.Resume(second, do))
+	if third.Succeeded != 3 || third.Failed != 0 {
+		t.Fatalf("Unexpected third counts: %+v", third)
+	}
+	for user, expected := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		if calls[user] != expected {
+			t.Errorf("Expected %s to be run %d time(s), got %d", user, expected, calls[user])
+		}
+	}
+}
+
+func TestResumeFromDryRun(t *testing.T) {
+	entries := []bulk.Entry{{User: "a", Project: "p"}, {User: "b", Project: "p"}}
+	var out bytes.Buffer
+	dry := bulk.Run("spawn", entries, 2, bulk.DryRun(&out, "spawn"))
+	dry.DryRun = true
+	if dry.Succeeded != 0 || dry.Skipped != 2 || dry.Results[0].Error != "dry run" {
+		t.Fatalf("Expected dry run entries to be skipped, got %+v", dry)
+	}
+	if out.String() != "would spawn a/p\nwould spawn b/p\n" && out.String() != "would spawn b/p\nwould spawn a/p\n" {
+		t.Errorf("Unexpected dry run output %q", out.String())
+	}
+
+	// even a dry run report with succeeded entries, e.g. from an older version, skips nothing
+	dry.Results[0].Status = bulk.StatusSucceeded
+	calls := 0
+	report := bulk.Run("spawn", entries, 1, bulk.Resume(dry, func(bulk.Entry) error {
+		calls++
+		return nil
+	}))
+	if calls != 2 || report.Succeeded != 2 {
+		t.Errorf("Expected every entry to run after a dry run, got %d call(s) and %+v", calls, report)
+	}
+}
@@ -0,0 +1,109 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+const (
+	// Required roster column
+	ColumnUser = "user"
+	// Optional roster column, overriding the default project
+	ColumnProject = "project"
+)
+
+// Name of an env var column, [image:]NAME with an upper case NAME
+// Lower case names are roster columns, so typos like "projet" aren't passed to containers
+var envColumn = regexp.MustCompile(`^([a-z0-9][a-z0-9_.-]*:)?[A-Z_][A-Z0-9_]*$`)
+
+// A roster row: a project to act on, with its env var overrides
+type Entry struct {
+	// Line number in the roster, starting at 1 for the header
+	Line    int
+	User    string
+	Project string
+	EnvVars *runtimes.InputEnvVars
+}
+
+// Read a CSV roster with a header row
+// Columns other than user and project are env vars, named like spawn's -e flag (e.g. "mysql:MARIADB_PASSWORD")
+// with an upper case name, any other column is an error
+// Empty cells are ignored, so rows can override only some env vars
+func ReadRoster(r io.Reader, defaultProject string) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("roster is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	userCol, projectCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(name) {
+		case ColumnUser:
+			userCol = i
+		case ColumnProject:
+			projectCol = i
+		default:
+			if !envColumn.MatchString(name) {
+				return nil, fmt.Errorf("unknown column %q, env var columns must be [image:]NAME with an upper case NAME", name)
+			}
+		}
+	}
+	if userCol < 0 {
+		return nil, fmt.Errorf("roster has no %q column", ColumnUser)
+	}
+
+	entries := make([]Entry, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		entry := Entry{
+			Line:    line,
+			User:    strings.TrimSpace(record[userCol]),
+			Project: defaultProject,
+			EnvVars: runtimes.NewInputEnvVars(),
+		}
+		if projectCol >= 0 && strings.TrimSpace(record[projectCol]) != "" {
+			entry.Project = strings.TrimSpace(record[projectCol])
+		}
+		if entry.User == "" {
+			return nil, fmt.Errorf("line %d: empty user", line)
+		}
+		if entry.Project == "" {
+			return nil, fmt.Errorf("line %d: no project given", line)
+		}
+
+		for i, value := range record {
+			if i == userCol || i == projectCol || value == "" {
+				continue
+			}
+			if err := entry.EnvVars.Parse(header[i] + "=" + value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,75 @@
+package bulk_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/bulk"
+)
+
+func TestReadRoster(t *testing.T) {
+	roster := `user,project,mysql:MARIADB_DATABASE,FOO
+alice,,,bar
+# comment
+bob,tp2,bobdb,
+`
+	entries, err := bulk.ReadRoster(strings.NewReader(roster), "tp1")
+	if err != nil {
+		t.Fatalf("Didn't expect an error, but got one: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(entries))
+	}
+
+	alice, bob := entries[0], entries[1]
+	if alice.User != "alice" || alice.Project != "tp1" || alice.EnvVars.Global["FOO"] != "bar" {
+		t.Errorf("Unexpected first entry: %+v %v", alice, alice.EnvVars)
+	}
+	if _, exists := alice.EnvVars.PerImage["mysql"]; exists {
+		t.Errorf("Empty cell should be ignored, got %v", alice.EnvVars.PerImage)
+	}
+	if bob.User != "bob" || bob.Project != "tp2" || bob.EnvVars.PerImage["mysql"]["MARIADB_DATABASE"] != "bobdb" {
+		t.Errorf("Unexpected second entry: %+v %v", bob, bob.EnvVars)
+	}
+	if bob.Line != 4 {
+		t.Errorf("Expected line 4, got %d", bob.Line)
+	}
+
+	invalid := map[string]string{
+		"No user column": "name\nalice\n",
+		"Empty user":     "user\n\"\"\n",
+		"No project":     "user\nalice\n",
+		"Typo":           "user,projet\nalice,tp1\n",
+		"Spaces":         "user,project,MY VAR\nalice,tp1,x\n",
+		"Empty image":    "user,project,:FOO\nalice,tp1,x\n",
+	}
+	for name, roster := range invalid {
+		if _, err := bulk.ReadRoster(strings.NewReader(roster), ""); err == nil {
+			t.Errorf("%s: expected an error, but didn't get one", name)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	entries := []bulk.Entry{{User: "a", Project: "p"}, {User: "b", Project: "p"}, {User: "c", Project: "p"}}
+	report := bulk.Run("test", entries, 2, func(entry bulk.Entry) error {
+		switch entry.User {
+		case "b":
+			return errors.New("boom")
+		case "c":
+			return &bulk.ErrSkipped{Reason: "exists"}
+		}
+		return nil
+	})
+
+	if report.Succeeded != 1 || report.Failed != 1 || report.Skipped != 1 {
+		t.Errorf("Unexpected counts: %+v", report)
+	}
+	if report.Results[1].Error != "boom" {
+		t.Errorf("Expected error to be reported in order, got %+v", report.Results)
+	}
+	if !report.HasSucceeded("a", "p") || report.HasSucceeded("b", "p") {
+		t.Errorf("HasSucceeded doesn't match results")
+	}
+}
@@ -0,0 +1,78 @@
+package containers
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/sinux-l5d/studentbox/internal/audit"
+)
+
+// Name of the audit log in the data directory, when ManagerOptions.AuditLog is empty
+const auditFile = ".audit.jsonl"
+
+// Actions recorded in the audit log
+const (
+	ActionSpawn     = "spawn"
+	ActionStart     = "start"
+	ActionStop      = "stop"
+	ActionDestroy   = "destroy"
+	ActionUpdateEnv = "update-env"
+	ActionExtend    = "extend"
+	ActionGC        = "gc"
+)
+
+// Write an audit entry for an operation, completing it with time, principal and result
+// Failing to write is logged but doesn't fail the operation, which is already done
+func (m *Manager) audit(entry audit.Entry, err error) {
+	entry.Time = time.Now()
+	entry.Principal = m.principal
+	entry.Result = audit.Success
+	if err != nil {
+		entry.Result = audit.Failure
+		entry.Error = err.Error()
+	}
+	for _, sink := range m.auditSinks {
+		if err := sink.Write(entry); err != nil {
+			m.log.Error("failed to write audit entry", "action", entry.Action, "user", entry.User, "project", entry.Project, "error", err)
+		}
+	}
+}
+
+// Runtime label of a project's pod, empty if it can't be inspected
+func (m *Manager) podRuntime(user, project string) string {
+	inspect, err := pods.Inspect(*m.ctx, podName(user, project), nil)
+	if err != nil {
+		return ""
+	}
+	return inspect.Labels[L_RUNTIME]
+}
+
+// Names of env vars given to a pod, prefixed by the image for image-specific ones (e.g. mysql:NAME)
+func envVarNames(global map[string]string, perImage map[string]map[string]string) []string {
+	names := make([]string, 0, len(global))
+	for name := range global {
+		names = append(names, name)
+	}
+	for image, vars := range perImage {
+		for name := range vars {
+			names = append(names, image+":"+name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Read entries of the audit log file matching the filter
+func (m *Manager) QueryAudit(filter audit.Filter) ([]audit.Entry, error) {
+	file, err := os.Open(m.auditLog)
+	if os.IsNotExist(err) {
+		return []audit.Entry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return audit.Query(file, filter)
+}
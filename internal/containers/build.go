@@ -0,0 +1,80 @@
+package containers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	buildahDefine "github.com/containers/buildah/define"
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+// Build every *.containerfile of a runtime directory (e.g. runtimes/lamp)
+// Images are named like the generator does and tagged with runtimes.LocalTag
+// The runtime directory is the build context, THIS_DIR being "." like in the CI's context
+// Return the built images references
+func (m *Manager) BuildRuntime(runtimeDir string, out io.Writer) ([]string, error) {
+	runtimeDir, err := filepath.Abs(runtimeDir)
+	if err != nil {
+		return nil, err
+	}
+	runtimeName := filepath.Base(runtimeDir)
+	known := runtimes.OfficialRuntimes[runtimeName]
+
+	entries, err := os.ReadDir(runtimeDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".containerfile") {
+			files = append(files, entry.Name())
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no containerfile found in %s", runtimeDir)
+	}
+	sort.Strings(files)
+
+	if out == nil {
+		out = io.Discard
+	}
+
+	built := make([]string, 0, len(files))
+	for _, file := range files {
+		shortName := strings.TrimSuffix(file, ".containerfile")
+
+		// keep the name of the generated runtime, which may use another prefix
+		name := runtimes.ImageName(runtimes.DefaultImagePrefix, runtimeName, shortName)
+		if image, exists := known.Images[shortName]; exists {
+			name = image.FullyQualifiedName
+		}
+		reference := name + ":" + runtimes.LocalTag
+
+		options := entities.BuildOptions{BuildOptions: buildahDefine.BuildOptions{
+			ContextDirectory: runtimeDir,
+			Args:             map[string]string{"THIS_DIR": "."},
+			Output:           reference,
+			// OCI format would drop HEALTHCHECK instructions
+			OutputFormat: buildahDefine.Dockerv2ImageManifest,
+			Layers:       true,
+			Out:          out,
+			Err:          out,
+			ReportWriter: out,
+		}}
+
+		fmt.Fprintf(out, "Building %s from %s\n", reference, file)
+		report, err := images.Build(*m.ctx, []string{filepath.Join(runtimeDir, file)}, options)
+		if err != nil {
+			return built, fmt.Errorf("failed to build %s: %w", file, err)
+		}
+		m.log.Info("built image", "image", reference, "image_id", report.ID)
+		built = append(built, reference)
+	}
+	return built, nil
+}
@@ -3,17 +3,24 @@ package containers
 import (
 	"context"
 	"strings"
+	"time"
 
+	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/containers/podman/v4/pkg/domain/entities"
 )
 
+// A snapshot of a container: inspect data is fetched once, on first use, and never refreshed
+// Get a new Container (e.g. with Manager.GetContainers) for up-to-date information
+// Not safe for concurrent use
 type Container struct {
 	// Contect from bindings.NewConnection to execute operations
 	ctx     context.Context
 	Name    string
 	User    string
 	Project string
+	// Inspect data shared by all getters, fetched on first use
+	inspect *define.InspectContainerData
 }
 
 func NewFromListContainer(ctx context.Context, container entities.ListContainer) *Container {
@@ -25,16 +32,94 @@ func NewFromListContainer(ctx context.Context, container entities.ListContainer)
 	}
 }
 
-func (c *Container) Status() (string, error) {
+// Inspect the container if not done yet
+func (c *Container) inspectData() (*define.InspectContainerData, error) {
+	if c.inspect != nil {
+		return c.inspect, nil
+	}
 	inspect, err := containers.Inspect(c.ctx, c.Name, &containers.InspectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	c.inspect = inspect
+	return inspect, nil
+}
+
+func (c *Container) Status() (string, error) {
+	inspect, err := c.inspectData()
 	if err != nil {
 		return "", err
 	}
 	return inspect.State.Status, nil
 }
 
+// Return the health status of the container (starting, healthy, unhealthy)
+// Empty if the container has no healthcheck
+func (c *Container) Health() (string, error) {
+	inspect, err := c.inspectData()
+	if err != nil {
+		return "", err
+	}
+	return inspect.State.Health.Status, nil
+}
+
+// A container restarted at least crashLoopRestarts times, exiting with an error
+// after running less than crashLoopMinRuntime, is crash looping
+const (
+	crashLoopRestarts   = 3
+	crashLoopMinRuntime = time.Minute
+)
+
+// Execution state of a container
+type ContainerState struct {
+	// created, running, exited...
+	Status    string `json:"status"`
+	ExitCode  int32  `json:"exit_code"`
+	OOMKilled bool   `json:"oom_killed"`
+	// Error of the last start, if any
+	Error         string    `json:"error,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	RestartCount  int32     `json:"restart_count"`
+	RestartPolicy string    `json:"restart_policy,omitempty"`
+	// Keeps failing shortly after being restarted
+	CrashLooping bool `json:"crash_looping"`
+}
+
+func (c *Container) State() (*ContainerState, error) {
+	inspect, err := c.inspectData()
+	if err != nil {
+		return nil, err
+	}
+	state := &ContainerState{
+		Status:       inspect.State.Status,
+		ExitCode:     inspect.State.ExitCode,
+		OOMKilled:    inspect.State.OOMKilled,
+		Error:        inspect.State.Error,
+		StartedAt:    inspect.State.StartedAt,
+		FinishedAt:   inspect.State.FinishedAt,
+		RestartCount: inspect.RestartCount,
+	}
+	if inspect.HostConfig != nil {
+		state.RestartPolicy = inspect.HostConfig.RestartPolicy.Name
+	}
+	state.CrashLooping = isCrashLooping(state, time.Now())
+	return state, nil
+}
+
+func isCrashLooping(state *ContainerState, now time.Time) bool {
+	if state.RestartCount < crashLoopRestarts || state.ExitCode == 0 {
+		return false
+	}
+	end := state.FinishedAt
+	if state.Status == "running" {
+		end = now
+	}
+	return end.Sub(state.StartedAt) < crashLoopMinRuntime
+}
+
 func (c *Container) GetEnv() (map[string]string, error) {
-	inspect, err := containers.Inspect(c.ctx, c.Name, &containers.InspectOptions{})
+	inspect, err := c.inspectData()
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +139,7 @@ func (c *Container) GetEnv() (map[string]string, error) {
 // Return the HostIP and HostPort of the container
 // Works only on infra containers, return empty strings if not found
 func (c *Container) GetPort() (string, string, error) {
-	inspect, err := containers.Inspect(c.ctx, c.Name, &containers.InspectOptions{})
+	inspect, err := c.inspectData()
 	if err != nil {
 		return "", "", err
 	}
@@ -64,4 +149,74 @@ func (c *Container) GetPort() (string, string, error) {
 		return "", "", nil
 	}
 	return config[0].HostIP, config[0].HostPort, nil
+}
+
+type Mount struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadOnly    bool   `json:"read_only"`
+}
+
+// Resource limits of a container, zero if unlimited
+type Limits struct {
+	MemoryBytes int64   `json:"memory_bytes"`
+	CPUs        float64 `json:"cpus"`
+	PIDs        int64   `json:"pids"`
+}
+
+// Studentbox-level view of a container's inspect data
+type ContainerInfo struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	ContainerState
+	// starting, healthy or unhealthy, empty without healthcheck
+	Health string `json:"health,omitempty"`
+	// Reference the container was created from, and digest of the image
+	Image       string  `json:"image"`
+	ImageDigest string  `json:"image_digest"`
+	Mounts      []Mount `json:"mounts"`
+	Limits      Limits  `json:"limits"`
+	// URL of the port published by the pod, empty if none
+	URL string `json:"url,omitempty"`
+}
+
+// Summarize the container's inspect data
+func (c *Container) Inspect() (*ContainerInfo, error) {
+	state, err := c.State()
+	if err != nil {
+		return nil, err
+	}
+	// cached by State
+	inspect, err := c.inspectData()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ContainerInfo{
+		Name:           c.Name,
+		ID:             inspect.ID,
+		ContainerState: *state,
+		Image:          inspect.ImageName,
+		ImageDigest:    inspect.ImageDigest,
+		Mounts:         make([]Mount, 0, len(inspect.Mounts)),
+	}
+	info.Health = inspect.State.Health.Status
+	for _, mount := range inspect.Mounts {
+		info.Mounts = append(info.Mounts, Mount{Source: mount.Source, Destination: mount.Destination, ReadOnly: !mount.RW})
+	}
+	if host := inspect.HostConfig; host != nil {
+		info.Limits.MemoryBytes = host.Memory
+		info.Limits.PIDs = host.PidsLimit
+		switch {
+		case host.NanoCpus > 0:
+			info.Limits.CPUs = float64(host.NanoCpus) / 1e9
+		case host.CpuQuota > 0 && host.CpuPeriod > 0:
+			info.Limits.CPUs = float64(host.CpuQuota) / float64(host.CpuPeriod)
+		}
+	}
+	// containers of a pod share the ports of the infra container
+	if inspect.NetworkSettings != nil {
+		info.URL = publishedURL(inspect.NetworkSettings.Ports["80/tcp"])
+	}
+	return info, nil
 }
\ No newline at end of file
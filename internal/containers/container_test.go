@@ -0,0 +1,45 @@
+package containers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsCrashLooping(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		state    ContainerState
+		expected bool
+	}{
+		{"never restarted", ContainerState{Status: "exited", ExitCode: 1, StartedAt: now.Add(-2 * time.Second), FinishedAt: now}, false},
+		{"restarting quickly", ContainerState{Status: "exited", ExitCode: 137, RestartCount: 3, StartedAt: now.Add(-2 * time.Second), FinishedAt: now}, true},
+		{"just restarted", ContainerState{Status: "running", ExitCode: 1, RestartCount: 4, StartedAt: now.Add(-10 * time.Second)}, true},
+		{"recovered", ContainerState{Status: "running", ExitCode: 1, RestartCount: 4, StartedAt: now.Add(-time.Hour)}, false},
+		{"long runs", ContainerState{Status: "exited", ExitCode: 1, RestartCount: 5, StartedAt: now.Add(-time.Hour), FinishedAt: now}, false},
+		{"clean exits", ContainerState{Status: "exited", ExitCode: 0, RestartCount: 5, StartedAt: now.Add(-time.Second), FinishedAt: now}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCrashLooping(&tt.state, now); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestContainerDown(t *testing.T) {
+	for status, expected := range map[string]bool{
+		"created":     false,
+		"initialized": false,
+		"configured":  false,
+		"stopping":    false,
+		"exited":      true,
+		"stopped":     true,
+		"dead":        true,
+	} {
+		if got := containerDown(status); got != expected {
+			t.Errorf("%s: expected down %v, got %v", status, expected, got)
+		}
+	}
+}
@@ -26,3 +26,102 @@ type ErrVolumeInvalid struct {
 func (e *ErrVolumeInvalid) Error() string {
 	return fmt.Sprintf("volume \"%s\" is invalid", e.Volume)
 }
+
+type ErrImageNotInRuntime struct {
+	Image   string
+	Runtime string
+}
+
+func (e *ErrImageNotInRuntime) Error() string {
+	return fmt.Sprintf("image \"%s\" doesn't exist in runtime \"%s\"", e.Image, e.Runtime)
+}
+
+type ErrRuntimeUnknown struct {
+	Runtime string
+}
+
+func (e *ErrRuntimeUnknown) Error() string {
+	return fmt.Sprintf("runtime \"%s\" is unknown", e.Runtime)
+}
+
+type ErrNotReady struct {
+	User    string
+	Project string
+	Reason  string
+}
+
+func (e *ErrNotReady) Error() string {
+	return fmt.Sprintf("project \"%s-%s\" is not ready: %s", e.User, e.Project, e.Reason)
+}
+
+type ErrImageNotAllowed struct {
+	Image string
+}
+
+func (e *ErrImageNotAllowed) Error() string {
+	return fmt.Sprintf("image \"%s\" is not allowed", e.Image)
+}
+
+type ErrImageNotPresent struct {
+	Image string
+}
+
+func (e *ErrImageNotPresent) Error() string {
+	return fmt.Sprintf("image \"%s\" doesn't exist locally and pull policy is never", e.Image)
+}
+
+type ErrImageDigestMismatch struct {
+	Image    string
+	Expected string
+	Actual   string
+}
+
+func (e *ErrImageDigestMismatch) Error() string {
+	return fmt.Sprintf("image \"%s\" has digest %s, expected pinned digest %s", e.Image, e.Actual, e.Expected)
+}
+
+type ErrSignatureInvalid struct {
+	Image string
+	Err   error
+}
+
+func (e *ErrSignatureInvalid) Error() string {
+	return fmt.Sprintf("image \"%s\" signature verification failed: %v", e.Image, e.Err)
+}
+
+func (e *ErrSignatureInvalid) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidNetwork struct {
+	Reason string
+}
+
+func (e *ErrInvalidNetwork) Error() string {
+	return fmt.Sprintf("invalid network options: %s", e.Reason)
+}
+
+type ErrNetworkConflict struct {
+	Network string
+	Reason  string
+}
+
+func (e *ErrNetworkConflict) Error() string {
+	return fmt.Sprintf("network \"%s\" doesn't match the requested options: %s", e.Network, e.Reason)
+}
+
+type ErrReservedProject struct {
+	Project string
+}
+
+func (e *ErrReservedProject) Error() string {
+	return fmt.Sprintf("project name \"%s\" is reserved", e.Project)
+}
+
+type ErrSharedImage struct {
+	Image string
+}
+
+func (e *ErrSharedImage) Error() string {
+	return fmt.Sprintf("image \"%s\" runs as a shared service of the user", e.Image)
+}
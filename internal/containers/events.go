@@ -0,0 +1,229 @@
+package containers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/containers/podman/v4/pkg/bindings/system"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+)
+
+// Types of lifecycle events
+const (
+	EventPodCreated       = "pod-created"
+	EventPodStarted       = "pod-started"
+	EventPodStopped       = "pod-stopped"
+	EventPodRemoved       = "pod-removed"
+	EventContainerCreated = "container-created"
+	EventContainerStarted = "container-started"
+	EventContainerDied    = "container-died"
+	EventContainerRemoved = "container-removed"
+	EventHealthChanged    = "health-changed"
+)
+
+// Podman event type and action to event type
+var eventTypes = map[string]string{
+	"pod/create":              EventPodCreated,
+	"pod/start":               EventPodStarted,
+	"pod/stop":                EventPodStopped,
+	"pod/remove":              EventPodRemoved,
+	"container/create":        EventContainerCreated,
+	"container/start":         EventContainerStarted,
+	"container/died":          EventContainerDied,
+	"container/remove":        EventContainerRemoved,
+	"container/health_status": EventHealthChanged,
+}
+
+// A change of a studentbox pod or container
+type Event struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Project string    `json:"project"`
+	PodID   string    `json:"pod_id,omitempty"`
+	// Empty for pod events
+	Container   string `json:"container,omitempty"`
+	ContainerID string `json:"container_id,omitempty"`
+	// Set for EventContainerDied
+	ExitCode *int `json:"exit_code,omitempty"`
+	// Set for EventHealthChanged (healthy, unhealthy, starting)
+	Health string `json:"health,omitempty"`
+}
+
+// Criteria to select events, zero values match everything
+type EventFilter struct {
+	User    string
+	Project string
+	Types   []string
+	// Replay past events since this date
+	Since time.Time
+	// Keep streaming new events, otherwise the stream ends after past events
+	Follow bool
+}
+
+func (f *EventFilter) match(event *Event) bool {
+	if (f.User != "" && event.User != f.User) || (f.Project != "" && event.Project != f.Project) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+type podOwner struct {
+	user, project string
+}
+
+// Stream lifecycle events of studentbox pods and containers until ctx is done or, without
+// filter.Follow, past events are read
+// Both channels are closed when the stream ends, errs receives at most one error
+func (m *Manager) Events(ctx context.Context, filter EventFilter) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+
+		// pod events don't carry labels, so owners are remembered by pod ID
+		owners, err := m.podOwners()
+		if err != nil {
+			errs <- err
+			return
+		}
+
+		options := &system.EventsOptions{
+			Stream:  &filter.Follow,
+			Filters: map[string][]string{"type": {"pod", "container"}},
+		}
+		if !filter.Since.IsZero() {
+			since := filter.Since.Format(time.RFC3339Nano)
+			options.Since = &since
+		}
+		raw := make(chan entities.Event)
+		cancel := make(chan bool)
+		done := make(chan error, 1)
+		go func() {
+			done <- system.Events(*m.ctx, raw, cancel, options)
+		}()
+		defer func() {
+			close(cancel)
+			// drain until system.Events closes raw
+			for range raw {
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-raw:
+				if !ok {
+					if err := <-done; err != nil {
+						errs <- fmt.Errorf("failed to read events: %w", err)
+					}
+					return
+				}
+				event, ok := translateEvent(e, owners, m.podOwner)
+				if !ok || !filter.match(&event) {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, errs
+}
+
+// Owner of a pod from its labels, false if it can't be inspected or isn't owned
+func (m *Manager) podOwner(podID string) (podOwner, bool) {
+	inspect, err := pods.Inspect(*m.ctx, podID, nil)
+	if err != nil || inspect.Labels[L_IS_OWNED] != "true" {
+		return podOwner{}, false
+	}
+	return podOwner{inspect.Labels[L_USER], inspect.Labels[L_PROJECT]}, true
+}
+
+func (m *Manager) podOwners() (map[string]podOwner, error) {
+	list, err := pods.List(*m.ctx, &pods.ListOptions{
+		Filters: map[string][]string{"label": {L_IS_OWNED + "=true"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	owners := make(map[string]podOwner, len(list))
+	for _, pod := range list {
+		owners[pod.Id] = podOwner{pod.Labels[L_USER], pod.Labels[L_PROJECT]}
+	}
+	return owners, nil
+}
+
+// Convert a podman event, false if it's not about a studentbox object or not interesting
+// Pods missing from owners are looked up with inspect and added
+func translateEvent(e entities.Event, owners map[string]podOwner, inspect func(podID string) (podOwner, bool)) (Event, bool) {
+	eventType, known := eventTypes[e.Type+"/"+e.Action]
+	if !known {
+		return Event{}, false
+	}
+	attributes := e.Actor.Attributes
+	event := Event{Type: eventType, Time: time.Unix(e.Time, 0)}
+	if e.TimeNano != 0 {
+		event.Time = time.Unix(0, e.TimeNano)
+	}
+
+	if e.Type == "pod" {
+		owner, owned := owners[e.Actor.ID]
+		if !owned && strings.HasPrefix(attributes["name"], PREFIX) {
+			// created after the stream started, can't be inspected anymore if removed
+			owner, owned = inspect(e.Actor.ID)
+			if owned {
+				owners[e.Actor.ID] = owner
+			}
+		}
+		if !owned {
+			return Event{}, false
+		}
+		if e.Action == "remove" {
+			delete(owners, e.Actor.ID)
+		}
+		event.PodID = e.Actor.ID
+		event.User, event.Project = owner.user, owner.project
+		return event, true
+	}
+
+	// container events carry the container labels
+	if attributes[L_IS_OWNED] != "true" {
+		return Event{}, false
+	}
+	event.User, event.Project = attributes[L_USER], attributes[L_PROJECT]
+	event.PodID = attributes["podId"]
+	event.Container = attributes["name"]
+	event.ContainerID = e.Actor.ID
+	if event.PodID != "" {
+		owners[event.PodID] = podOwner{event.User, event.Project}
+	}
+	switch eventType {
+	case EventContainerDied:
+		if code, err := strconv.Atoi(attributes["containerExitCode"]); err == nil {
+			event.ExitCode = &code
+		}
+	case EventHealthChanged:
+		event.Health = e.HealthStatus
+	}
+	return event, true
+}
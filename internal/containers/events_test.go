@@ -0,0 +1,82 @@
+package containers
+
+import (
+	"testing"
+
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	dockerEvents "github.com/docker/docker/api/types/events"
+)
+
+func TestTranslateEvent(t *testing.T) {
+	containerAttributes := func(extra map[string]string) map[string]string {
+		attributes := map[string]string{
+			L_IS_OWNED: "true", L_USER: "alice", L_PROJECT: "web",
+			"name": "sb-alice-web-mysql", "podId": "pod2",
+		}
+		for k, v := range extra {
+			attributes[k] = v
+		}
+		return attributes
+	}
+	inspected := map[string]podOwner{"pod3": {"bob", "api"}}
+	inspect := func(id string) (podOwner, bool) {
+		owner, ok := inspected[id]
+		return owner, ok
+	}
+
+	tests := []struct {
+		name     string
+		typ      string
+		action   string
+		id       string
+		attrs    map[string]string
+		health   string
+		ok       bool
+		expected Event
+	}{
+		{"known pod", "pod", "start", "pod1", map[string]string{"name": "sb-carol-db"}, "", true,
+			Event{Type: EventPodStarted, User: "carol", Project: "db", PodID: "pod1"}},
+		{"new pod inspected", "pod", "create", "pod3", map[string]string{"name": "sb-bob-api"}, "", true,
+			Event{Type: EventPodCreated, User: "bob", Project: "api", PodID: "pod3"}},
+		{"foreign pod", "pod", "create", "pod4", map[string]string{"name": "other"}, "", false, Event{}},
+		{"uninteresting action", "container", "mount", "c1", containerAttributes(nil), "", false, Event{}},
+		{"foreign container", "container", "start", "c1", map[string]string{"name": "other"}, "", false, Event{}},
+		{"container died", "container", "died", "c1", containerAttributes(map[string]string{"containerExitCode": "137"}), "", true,
+			Event{Type: EventContainerDied, User: "alice", Project: "web", PodID: "pod2", Container: "sb-alice-web-mysql", ContainerID: "c1"}},
+		{"health", "container", "health_status", "c1", containerAttributes(nil), "unhealthy", true,
+			Event{Type: EventHealthChanged, User: "alice", Project: "web", PodID: "pod2", Container: "sb-alice-web-mysql", ContainerID: "c1", Health: "unhealthy"}},
+		{"pod learned from container", "pod", "stop", "pod2", map[string]string{"name": "sb-alice-web"}, "", true,
+			Event{Type: EventPodStopped, User: "alice", Project: "web", PodID: "pod2"}},
+	}
+
+	owners := map[string]podOwner{"pod1": {"carol", "db"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := entities.Event{
+				Message: dockerEvents.Message{
+					Type:   tt.typ,
+					Action: tt.action,
+					Actor:  dockerEvents.Actor{ID: tt.id, Attributes: tt.attrs},
+				},
+				HealthStatus: tt.health,
+			}
+			event, ok := translateEvent(e, owners, inspect)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if event.Type == EventContainerDied {
+				if event.ExitCode == nil || *event.ExitCode != 137 {
+					t.Errorf("expected exit code 137, got %v", event.ExitCode)
+				}
+				event.ExitCode = nil
+			}
+			event.Time = tt.expected.Time
+			if event != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, event)
+			}
+		})
+	}
+}
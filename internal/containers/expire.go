@@ -0,0 +1,260 @@
+package containers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/sinux-l5d/studentbox/internal/audit"
+	"github.com/sinux-l5d/studentbox/internal/tools"
+)
+
+// Format of the L_EXPIRES label
+const expiresFormat = time.RFC3339
+
+type ExpireOptions struct {
+	// Time after the expiry date before pods are stopped
+	Grace time.Duration
+	// Call Warn this long before the expiry date, once per project
+	WarnBefore time.Duration
+	Warn       func(user, project string, expires time.Time) error
+	// Archive expired projects data to this directory, skipped if empty
+	SnapshotDir string
+	// Remove expired pods after stopping (and snapshotting) them
+	Destroy bool
+	// Only report, don't warn, stop or destroy anything
+	DryRun bool
+}
+
+// What ExpirePods did to a project
+type ExpireResult struct {
+	User    string    `json:"user"`
+	Project string    `json:"project"`
+	Expires time.Time `json:"expires"`
+	// Within WarnBefore of the expiry date
+	Warned bool `json:"warned,omitempty"`
+	// Past the expiry date and grace period
+	Expired   bool   `json:"expired,omitempty"`
+	Stopped   bool   `json:"stopped,omitempty"`
+	Snapshot  string `json:"snapshot,omitempty"`
+	Destroyed bool   `json:"destroyed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Get the expiry date of a project, zero if it never expires
+// Dates set by Extend take precedence over the one given at spawn
+func (m *Manager) GetExpiry(user, project string) (time.Time, error) {
+	state, err := m.GetState(user, project)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !state.ExpiresAt.IsZero() || state.NeverExpires {
+		return state.ExpiresAt, nil
+	}
+
+	inspect, err := pods.Inspect(*m.ctx, podName(user, project), nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to inspect pod: %w", err)
+	}
+	return expiryOf(state, inspect.Labels[L_EXPIRES])
+}
+
+// Expiry date of a project from its state and L_EXPIRES label, zero if it never expires
+func expiryOf(state *ProjectState, label string) (time.Time, error) {
+	if !state.ExpiresAt.IsZero() || state.NeverExpires {
+		return state.ExpiresAt, nil
+	}
+	return parseExpires(label)
+}
+
+func parseExpires(label string) (time.Time, error) {
+	if label == "" {
+		return time.Time{}, nil
+	}
+	expires, err := time.Parse(expiresFormat, label)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s label: %w", L_EXPIRES, err)
+	}
+	return expires, nil
+}
+
+// Set a new expiry date for a project, zero to never expire
+func (m *Manager) Extend(user, project string, expires time.Time) (err error) {
+	defer func() {
+		detail := "never"
+		if !expires.IsZero() {
+			detail = expires.Format(expiresFormat)
+		}
+		m.audit(audit.Entry{Action: ActionExtend, User: user, Project: project, Runtime: m.podRuntime(user, project), Detail: detail}, err)
+	}()
+
+	exists, err := m.PodExists(user, project)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ErrContainerDontExists{User: user, Project: project}
+	}
+
+	err = m.updateState(user, project, func(state *ProjectState) {
+		state.ExpiresAt = expires
+		state.NeverExpires = expires.IsZero()
+		state.ExpiryWarned = false
+		state.Expired = false
+	})
+	if err != nil {
+		return err
+	}
+	if expires.IsZero() {
+		m.log.Info("removed pod expiry", "user", user, "project", project)
+	} else {
+		m.log.Info("extended pod", "user", user, "project", project, "expires", expires)
+	}
+	return nil
+}
+
+// Warn about projects close to their expiry date, then stop, snapshot and destroy expired ones
+// Must be called periodically, projects are warned only once
+func (m *Manager) ExpirePods(opt ExpireOptions) ([]ExpireResult, error) {
+	list, err := pods.List(*m.ctx, &pods.ListOptions{
+		Filters: map[string][]string{"label": {L_IS_OWNED + "=true"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	now := time.Now()
+	results := make([]ExpireResult, 0)
+	for _, pod := range list {
+		result := ExpireResult{User: pod.Labels[L_USER], Project: pod.Labels[L_PROJECT]}
+		state, err := m.GetState(result.User, result.Project)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		if result.Expires, err = expiryOf(state, pod.Labels[L_EXPIRES]); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		switch expireAction(state, result.Expires, now, opt) {
+		case expireNow:
+			result.Expired = true
+			if !opt.DryRun {
+				err = m.expirePod(&result, pod.Status == "Running", opt)
+			}
+		case expireWarn:
+			result.Warned = true
+			if !opt.DryRun {
+				err = m.warnExpiry(result, opt)
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			result.Error = err.Error()
+			m.log.Error("failed to expire pod", "user", result.User, "project", result.Project, "error", err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// What ExpirePods does to a project
+const (
+	expireNothing = iota
+	expireWarn
+	expireNow
+)
+
+// Decide what to do with a project expiring at expires, zero if it never expires
+// Past the grace period a project expires even if it was never warned
+func expireAction(state *ProjectState, expires, now time.Time, opt ExpireOptions) int {
+	switch {
+	case expires.IsZero():
+		return expireNothing
+	case state.Expired:
+		// already handled by a previous call, until extended
+		return expireNothing
+	case now.After(expires.Add(opt.Grace)):
+		return expireNow
+	case now.After(expires.Add(-opt.WarnBefore)) && !state.ExpiryWarned:
+		return expireWarn
+	}
+	return expireNothing
+}
+
+func (m *Manager) warnExpiry(result ExpireResult, opt ExpireOptions) error {
+	m.log.Warn("pod expires soon", "user", result.User, "project", result.Project, "expires", result.Expires)
+	m.notify(WebhookExpiryWarning, result.User, result.Project, map[string]any{"expires": result.Expires})
+	if opt.Warn != nil {
+		if err := opt.Warn(result.User, result.Project, result.Expires); err != nil {
+			return fmt.Errorf("failed to warn: %w", err)
+		}
+	}
+	return m.updateState(result.User, result.Project, func(state *ProjectState) {
+		state.ExpiryWarned = true
+	})
+}
+
+func (m *Manager) expirePod(result *ExpireResult, running bool, opt ExpireOptions) error {
+	if running {
+		if err := m.stopPod(result.User, result.Project, StopReasonExpired); err != nil {
+			return err
+		}
+		result.Stopped = true
+	}
+	if opt.SnapshotDir != "" {
+		snapshot, err := m.Snapshot(result.User, result.Project, opt.SnapshotDir)
+		if err != nil {
+			return err
+		}
+		result.Snapshot = snapshot
+	}
+	if opt.Destroy {
+		if err := m.DestroyPod(result.User, result.Project); err != nil {
+			return err
+		}
+		result.Destroyed = true
+	}
+
+	m.notify(WebhookExpired, result.User, result.Project, map[string]any{
+		"expires":   result.Expires,
+		"snapshot":  result.Snapshot,
+		"destroyed": result.Destroyed,
+	})
+	return m.updateState(result.User, result.Project, func(state *ProjectState) {
+		state.Expired = true
+	})
+}
+
+// Archive a project's data directory to dir, returning the archive's path
+// Its data in shared services (e.g. its database) is dumped in the archive, as <image>.dump
+// The pod should be stopped to get a consistent snapshot
+func (m *Manager) Snapshot(user, project, dir string) (string, error) {
+	if err := tools.EnsureDirCreated(dir); err != nil {
+		return "", err
+	}
+	projectDir := filepath.Join(m.dataPath, user, project)
+	// dumps are written in the project directory, which isn't mounted in containers, only for the archive
+	dumps, err := m.dumpShared(user, project, projectDir)
+	defer func() {
+		for _, dump := range dumps {
+			os.Remove(dump)
+		}
+	}()
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot %s/%s: %w", user, project, err)
+	}
+	name := fmt.Sprintf("%s-%s-%s.tar.gz", user, project, time.Now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	if err := tools.TarGz(projectDir, path); err != nil {
+		return "", fmt.Errorf("failed to snapshot %s/%s: %w", user, project, err)
+	}
+	m.log.Info("wrote snapshot", "user", user, "project", project, "path", path)
+	return path, nil
+}
@@ -0,0 +1,70 @@
+package containers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryOf(t *testing.T) {
+	extended := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	label := "2023-06-01T00:00:00Z"
+	tests := []struct {
+		name     string
+		state    ProjectState
+		label    string
+		expected time.Time
+		valid    bool
+	}{
+		{"label", ProjectState{}, label, time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{"no label", ProjectState{}, "", time.Time{}, true},
+		{"extended", ProjectState{ExpiresAt: extended}, label, extended, true},
+		{"never expires", ProjectState{NeverExpires: true}, label, time.Time{}, true},
+		{"invalid label", ProjectState{}, "tomorrow", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expiryOf(&tt.state, tt.label)
+			if !tt.valid {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExpireAction(t *testing.T) {
+	expires := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	opt := ExpireOptions{Grace: time.Hour, WarnBefore: 24 * time.Hour}
+	tests := []struct {
+		name     string
+		state    ProjectState
+		expires  time.Time
+		now      time.Time
+		expected int
+	}{
+		{"never expires", ProjectState{}, time.Time{}, expires, expireNothing},
+		{"far away", ProjectState{}, expires, expires.Add(-48 * time.Hour), expireNothing},
+		{"warn", ProjectState{}, expires, expires.Add(-time.Hour), expireWarn},
+		{"already warned", ProjectState{ExpiryWarned: true}, expires, expires.Add(-time.Hour), expireNothing},
+		{"within grace", ProjectState{}, expires, expires.Add(30 * time.Minute), expireWarn},
+		{"within grace warned", ProjectState{ExpiryWarned: true}, expires, expires.Add(30 * time.Minute), expireNothing},
+		{"past grace without warning", ProjectState{}, expires, expires.Add(2 * time.Hour), expireNow},
+		{"past grace", ProjectState{ExpiryWarned: true}, expires, expires.Add(2 * time.Hour), expireNow},
+		{"already expired", ProjectState{ExpiryWarned: true, Expired: true}, expires, expires.Add(2 * time.Hour), expireNothing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expireAction(&tt.state, tt.expires, tt.now, opt); got != tt.expected {
+				t.Errorf("expected action %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
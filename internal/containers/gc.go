@@ -0,0 +1,297 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"github.com/containers/podman/v4/pkg/bindings/network"
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/sinux-l5d/studentbox/internal/audit"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+// Kinds of orphaned resources found by GarbageCollect
+const (
+	// Pod without any container besides its infra container
+	OrphanPod = "pod"
+	// Studentbox container whose pod doesn't exist anymore
+	OrphanContainer = "container"
+	// Runtime image not used by any container
+	OrphanImage = "image"
+	// Project data directory without pod
+	OrphanData = "data"
+	// Network created for projects no pod is connected to
+	OrphanNetwork = "network"
+)
+
+type GCOptions struct {
+	// Only report orphans, don't remove anything
+	DryRun bool
+	// Ignore resources created (or modified, for data) more recently than this
+	OlderThan time.Duration
+	// Also remove projects data directories, which are otherwise only reported
+	RemoveData bool
+}
+
+// An orphaned resource and what happened to it
+type Orphan struct {
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Removed bool      `json:"removed"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Find resources left behind by failures, crashes or manual podman commands and remove them
+// Orphans are found through the studentbox labels and the PREFIX naming
+// Failing to remove an orphan doesn't stop the collection, see Orphan.Error
+func (m *Manager) GarbageCollect(opt GCOptions) ([]Orphan, error) {
+	cutoff := time.Now().Add(-opt.OlderThan)
+	orphans := make([]Orphan, 0)
+
+	finders := []func(time.Time) ([]Orphan, error){
+		m.findOrphanPods,
+		m.findOrphanContainers,
+		m.findOrphanImages,
+		m.findOrphanNetworks,
+		m.findOrphanData,
+	}
+	for _, find := range finders {
+		found, err := find(cutoff)
+		if err != nil {
+			return orphans, err
+		}
+		orphans = append(orphans, found...)
+	}
+
+	if opt.DryRun {
+		return orphans, nil
+	}
+	for i := range orphans {
+		if orphans[i].Kind == OrphanData && !opt.RemoveData {
+			continue
+		}
+		err := m.removeOrphan(orphans[i])
+		m.audit(audit.Entry{Action: ActionGC, Detail: orphans[i].Kind + " " + orphans[i].Name}, err)
+		if err != nil {
+			orphans[i].Error = err.Error()
+			m.log.Error("failed to remove orphan", "kind", orphans[i].Kind, "name", orphans[i].Name, "error", err)
+			continue
+		}
+		orphans[i].Removed = true
+		m.log.Info("removed orphan", "kind", orphans[i].Kind, "name", orphans[i].Name)
+	}
+	return orphans, nil
+}
+
+func (m *Manager) removeOrphan(orphan Orphan) error {
+	force := true
+	switch orphan.Kind {
+	case OrphanPod:
+		_, err := pods.Remove(*m.ctx, orphan.Name, &pods.RemoveOptions{Force: &force})
+		return err
+	case OrphanContainer:
+		_, err := containers.Remove(*m.ctx, orphan.Name, &containers.RemoveOptions{Force: &force})
+		return err
+	case OrphanImage:
+		_, errs := images.Remove(*m.ctx, []string{orphan.Name}, nil)
+		return errors.Join(errs...)
+	case OrphanNetwork:
+		_, err := network.Remove(*m.ctx, orphan.Name, nil)
+		return err
+	case OrphanData:
+		if err := os.RemoveAll(filepath.Join(m.dataPath, orphan.Name)); err != nil {
+			return err
+		}
+		// remove the user directory too if it was its last project
+		userDir := filepath.Join(m.dataPath, filepath.Dir(orphan.Name))
+		if entries, err := os.ReadDir(userDir); err == nil && len(entries) == 0 {
+			return os.Remove(userDir)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown orphan kind %s", orphan.Kind)
+}
+
+// Pods named with PREFIX or labelled as owned, with only an infra container,
+// and shared services pods no project uses anymore
+func (m *Manager) findOrphanPods(cutoff time.Time) ([]Orphan, error) {
+	list, err := pods.List(*m.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	usesShared := make(map[string]bool)
+	for _, pod := range list {
+		if len(usedShared(pod.Labels)) > 0 {
+			usesShared[pod.Labels[L_USER]] = true
+		}
+	}
+
+	orphans := make([]Orphan, 0)
+	for _, pod := range list {
+		if isOrphanPod(pod, usesShared, cutoff) {
+			orphans = append(orphans, Orphan{Kind: OrphanPod, Name: pod.Name, Created: pod.Created})
+		}
+	}
+	return orphans, nil
+}
+
+// Whether a pod is an orphan, usesShared being the users with a project using shared services
+func isOrphanPod(pod *entities.ListPodsReport, usesShared map[string]bool, cutoff time.Time) bool {
+	if pod.Labels[L_IS_OWNED] != "true" && !strings.HasPrefix(pod.Name, PREFIX) {
+		return false
+	}
+	if pod.Created.After(cutoff) {
+		return false
+	}
+	if pod.Labels[L_SHARED] == "true" {
+		return !usesShared[pod.Labels[L_USER]]
+	}
+	for _, container := range pod.Containers {
+		if container.Id != pod.InfraId {
+			return false
+		}
+	}
+	return true
+}
+
+// Containers labelled as owned, outside of any existing pod
+func (m *Manager) findOrphanContainers(cutoff time.Time) ([]Orphan, error) {
+	all := true
+	list, err := containers.List(*m.ctx, &containers.ListOptions{
+		All: &all,
+		Filters: map[string][]string{
+			"label": {L_IS_OWNED + "=true"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	orphans := make([]Orphan, 0)
+	for _, container := range list {
+		if container.Created.After(cutoff) {
+			continue
+		}
+		if container.Pod != "" {
+			exists, err := pods.Exists(*m.ctx, container.Pod, nil)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				continue
+			}
+		}
+		orphans = append(orphans, Orphan{Kind: OrphanContainer, Name: container.Names[0], Created: container.Created})
+	}
+	return orphans, nil
+}
+
+// Images of official runtimes, as pulled or built locally, not used by any container
+func (m *Manager) findOrphanImages(cutoff time.Time) ([]Orphan, error) {
+	names := make(map[string]struct{})
+	for _, runtime := range runtimes.OfficialRuntimes {
+		for _, image := range runtime.Images {
+			names[image.FullyQualifiedName] = struct{}{}
+		}
+		for _, image := range runtime.WithMirrors(m.mirrors).Images {
+			names[image.FullyQualifiedName] = struct{}{}
+		}
+	}
+
+	list, err := images.List(*m.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	orphans := make([]Orphan, 0)
+	for _, image := range list {
+		created := time.Unix(image.Created, 0)
+		if image.Containers > 0 || created.After(cutoff) {
+			continue
+		}
+		for _, tag := range image.RepoTags {
+			name := tag
+			if i := strings.LastIndex(tag, ":"); i > strings.LastIndex(tag, "/") {
+				name = tag[:i]
+			}
+			if _, isRuntime := names[name]; isRuntime {
+				orphans = append(orphans, Orphan{Kind: OrphanImage, Name: tag, Created: created})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// Networks younger than this are never orphans, whatever GCOptions.OlderThan
+// gc doesn't take the users locks, and spawns create networks right before their pod
+const networkMinAge = time.Minute
+
+// Networks labelled as owned, not used by any pod
+func (m *Manager) findOrphanNetworks(cutoff time.Time) ([]Orphan, error) {
+	if minCutoff := time.Now().Add(-networkMinAge); cutoff.After(minCutoff) {
+		cutoff = minCutoff
+	}
+	nets, err := m.unusedNetworks(map[string][]string{
+		"label": {L_IS_OWNED + "=true"},
+	}, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := make([]Orphan, 0, len(nets))
+	for _, net := range nets {
+		orphans = append(orphans, Orphan{Kind: OrphanNetwork, Name: net.Name, Created: net.Created})
+	}
+	return orphans, nil
+}
+
+// Directories <user>/<project> of the data directory without pod
+func (m *Manager) findOrphanData(cutoff time.Time) ([]Orphan, error) {
+	users, err := os.ReadDir(m.dataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	orphans := make([]Orphan, 0)
+	for _, user := range users {
+		if !user.IsDir() {
+			continue
+		}
+		projects, err := os.ReadDir(filepath.Join(m.dataPath, user.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, project := range projects {
+			if !project.IsDir() {
+				continue
+			}
+			info, err := project.Info()
+			if err != nil {
+				return nil, err
+			}
+			exists, err := m.PodExists(user.Name(), project.Name())
+			if err != nil {
+				return nil, err
+			}
+			if isOrphanData(info.ModTime(), exists, cutoff) {
+				orphans = append(orphans, Orphan{Kind: OrphanData, Name: filepath.Join(user.Name(), project.Name()), Created: info.ModTime()})
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// Whether a project data directory is an orphan
+// The shared services data directory is one once no project uses them, see releaseShared
+func isOrphanData(modified time.Time, podExists bool, cutoff time.Time) bool {
+	return !podExists && !modified.After(cutoff)
+}
@@ -0,0 +1,62 @@
+package containers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/domain/entities"
+)
+
+func TestIsOrphanPod(t *testing.T) {
+	cutoff := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	old := cutoff.Add(-time.Hour)
+	infra := &entities.ListPodContainer{Id: "infra"}
+	app := &entities.ListPodContainer{Id: "app"}
+	owned := map[string]string{L_IS_OWNED: "true", L_USER: "alice"}
+	shared := map[string]string{L_IS_OWNED: "true", L_USER: "alice", L_SHARED: "true"}
+
+	tests := []struct {
+		name       string
+		pod        entities.ListPodsReport
+		usesShared map[string]bool
+		orphan     bool
+	}{
+		{"empty", entities.ListPodsReport{Name: "sb-alice-blog", Labels: owned, Created: old, InfraId: "infra", Containers: []*entities.ListPodContainer{infra}}, nil, true},
+		{"with containers", entities.ListPodsReport{Name: "sb-alice-blog", Labels: owned, Created: old, InfraId: "infra", Containers: []*entities.ListPodContainer{infra, app}}, nil, false},
+		{"spawn in progress", entities.ListPodsReport{Name: "sb-alice-blog", Labels: owned, Created: cutoff.Add(time.Minute), InfraId: "infra"}, nil, false},
+		{"prefix only", entities.ListPodsReport{Name: "sb-bob-blog", Created: old}, nil, true},
+		{"not ours", entities.ListPodsReport{Name: "nginx", Created: old}, nil, false},
+		{"unused shared", entities.ListPodsReport{Name: "sb-alice-shared", Labels: shared, Created: old, InfraId: "infra", Containers: []*entities.ListPodContainer{infra, app}}, map[string]bool{"bob": true}, true},
+		{"used shared", entities.ListPodsReport{Name: "sb-alice-shared", Labels: shared, Created: old, InfraId: "infra", Containers: []*entities.ListPodContainer{infra, app}}, map[string]bool{"alice": true}, false},
+		{"recent unused shared", entities.ListPodsReport{Name: "sb-alice-shared", Labels: shared, Created: cutoff.Add(time.Minute)}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOrphanPod(&tt.pod, tt.usesShared, cutoff); got != tt.orphan {
+				t.Errorf("expected orphan %t, got %t", tt.orphan, got)
+			}
+		})
+	}
+}
+
+func TestIsOrphanData(t *testing.T) {
+	cutoff := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		modified  time.Time
+		podExists bool
+		orphan    bool
+	}{
+		{"without pod", cutoff.Add(-time.Hour), false, true},
+		{"at cutoff", cutoff, false, true},
+		{"with pod", cutoff.Add(-time.Hour), true, false},
+		{"spawn in progress", cutoff.Add(time.Second), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOrphanData(tt.modified, tt.podExists, cutoff); got != tt.orphan {
+				t.Errorf("expected orphan %t, got %t", tt.orphan, got)
+			}
+		})
+	}
+}
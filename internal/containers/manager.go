@@ -4,20 +4,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/podman/v4/libpod/define"
 	"github.com/containers/podman/v4/pkg/bindings"
 	"github.com/containers/podman/v4/pkg/bindings/containers"
 	"github.com/containers/podman/v4/pkg/bindings/images"
 	"github.com/containers/podman/v4/pkg/bindings/pods"
 	"github.com/containers/podman/v4/pkg/domain/entities"
 	"github.com/containers/podman/v4/pkg/specgen"
+	"github.com/sinux-l5d/studentbox/internal/audit"
+	"github.com/sinux-l5d/studentbox/internal/metrics"
 	"github.com/sinux-l5d/studentbox/internal/runtimes"
 	"github.com/sinux-l5d/studentbox/internal/tools"
+	"github.com/sinux-l5d/studentbox/internal/webhook"
+	"golang.org/x/exp/slog"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 // Object handling containers creation and retrieving.
@@ -27,7 +39,32 @@ type Manager struct {
 	socketPath string
 	hostPath   string
 	dataPath   string
-	log        *log.Logger
+	log        *slog.Logger
+	// Maximum number of concurrent pulls and container creations per call
+	concurrency int
+	// Deduplicate concurrent pulls of the same image
+	pulls singleflight.Group
+	// Image name prefixes allowed to be pulled, all if empty
+	allowedImages []string
+	// Path of the public key images must be signed with, no verification if empty
+	signatureKey string
+	// References already verified, key is the reference, value is always true
+	verified sync.Map
+	// Registry mirrors applied to runtime images
+	mirrors map[string]string
+	// Registry credentials file, podman's default if empty
+	authFile string
+	// Counters and histograms exposed by Collect
+	metrics *metrics.Store
+	// Who operates the manager, recorded in the audit log
+	principal string
+	// Path of the audit log file, also written to auditSinks
+	auditLog   string
+	auditSinks []audit.Sink
+	// Where to send lifecycle notifications, none if nil
+	webhooks *webhook.Dispatcher
+	// Network of pods spawned without PodOptions.Network
+	network NetworkOptions
 }
 
 // Option when creating a Manager
@@ -38,7 +75,34 @@ type ManagerOptions struct {
 	// Relative path of data directory (e.g. data/)
 	// Note that HostPath + DataPath is the absolute path of data directory on host
 	DataPath string
-	Logger   *log.Logger
+	// Structured logger, logs are discarded if nil
+	Logger *slog.Logger
+	// Maximum number of concurrent pulls and container creations per call, at least 1
+	Concurrency int
+	// Registries or image name prefixes allowed to be pulled (e.g. ghcr.io/sinux-l5d/studentbox)
+	// All images are allowed if empty
+	AllowedImages []string
+	// Path of a sigstore public key (e.g. cosign.pub) images must be signed with
+	// Signatures are not verified if empty
+	SignatureKey string
+	// Rewrite runtime images names, see runtimes.Runtime.WithMirrors
+	Mirrors map[string]string
+	// Registry credentials file (e.g. ${XDG_RUNTIME_DIR}/containers/auth.json) used for pulls
+	AuthFile string
+	// Where to persist metrics, .metrics.json in DataPath if empty
+	MetricsPath string
+	// Who operates the manager (e.g. a teacher's login), recorded in the audit log
+	Principal string
+	// Audit log file, .audit.jsonl in DataPath if empty
+	AuditLog string
+	// Also send audit entries to syslog
+	AuditSyslog bool
+	// Endpoints notified of lifecycle events
+	Webhooks []webhook.Hook
+	// Where undelivered webhooks are written, .webhooks-dead.jsonl in DataPath if empty
+	WebhookDeadLetter string
+	// Network of pods spawned without PodOptions.Network, podman's default network if zero
+	Network NetworkOptions
 }
 
 const (
@@ -54,6 +118,24 @@ const (
 	// The project associated to the user
 	L_PROJECT = L_BASE + ".project"
 
+	// The runtime the pod was spawned from
+	L_RUNTIME = L_BASE + ".runtime"
+
+	// The pod uses images built locally
+	L_LOCAL = L_BASE + ".local"
+
+	// When the project expires, in RFC 3339 format
+	L_EXPIRES = L_BASE + ".expires"
+
+	// Restart policy overriding the runtime's one
+	L_RESTART = L_BASE + ".restart"
+
+	// The pod runs the shared services of a user
+	L_SHARED = L_BASE + ".shared"
+
+	// Comma-separated shared images used by the project, counted as references
+	L_USES_SHARED = L_BASE + ".uses_shared"
+
 	// Image-specific config
 	L_CONFIG        = L_BASE + ".config"
 	L_CONFIG_MOUNTS = L_CONFIG + ".mounts"
@@ -69,10 +151,11 @@ func DefaultManagerOptions() *ManagerOptions {
 		sockDir = "/tmp"
 	}
 	return &ManagerOptions{
-		SocketPath: "unix://" + sockDir + "/podman/podman.sock",
-		DataPath:   "./data",
-		HostPath:   pwd,
-		Logger:     log.New(os.Stdout, "[containers] ", log.LstdFlags),
+		SocketPath:  "unix://" + sockDir + "/podman/podman.sock",
+		DataPath:    "./data",
+		HostPath:    pwd,
+		Logger:      slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		Concurrency: 4,
 	}
 }
 
@@ -96,12 +179,68 @@ func NewManager(opt *ManagerOptions) (*Manager, error) {
 		return nil, errors.New("host path must be an absolute path, current value: \"" + opt.HostPath + "\"")
 	}
 
+	logger := opt.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	metricsPath := opt.MetricsPath
+	if metricsPath == "" {
+		metricsPath = filepath.Join(opt.DataPath, metricsFile)
+	}
+
+	auditLog := opt.AuditLog
+	if auditLog == "" {
+		auditLog = filepath.Join(opt.DataPath, auditFile)
+	}
+	auditSinks := []audit.Sink{&audit.FileSink{Path: auditLog}}
+	if opt.AuditSyslog {
+		sink, err := audit.NewSyslogSink()
+		if err != nil {
+			return nil, err
+		}
+		auditSinks = append(auditSinks, sink)
+	}
+
+	var webhooks *webhook.Dispatcher
+	if len(opt.Webhooks) > 0 {
+		deadLetter := opt.WebhookDeadLetter
+		if deadLetter == "" {
+			deadLetter = filepath.Join(opt.DataPath, webhookDeadLetterFile)
+		}
+		webhooks = webhook.NewDispatcher(opt.Webhooks, webhook.Options{
+			Retries:    webhookRetries,
+			Backoff:    webhookBackoff,
+			DeadLetter: deadLetter,
+		})
+	}
+
+	if err := opt.Network.Validate(); err != nil {
+		return nil, err
+	}
+
+	concurrency := opt.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	return &Manager{
-		ctx:        &ctx,
-		socketPath: opt.SocketPath,
-		log:        opt.Logger,
-		hostPath:   opt.HostPath,
-		dataPath:   opt.DataPath,
+		ctx:           &ctx,
+		socketPath:    opt.SocketPath,
+		log:           logger,
+		hostPath:      opt.HostPath,
+		dataPath:      opt.DataPath,
+		concurrency:   concurrency,
+		allowedImages: opt.AllowedImages,
+		signatureKey:  opt.SignatureKey,
+		mirrors:       opt.Mirrors,
+		authFile:      opt.AuthFile,
+		metrics:       newMetricsStore(metricsPath),
+		principal:     opt.Principal,
+		auditLog:      auditLog,
+		auditSinks:    auditSinks,
+		webhooks:      webhooks,
+		network:       opt.Network,
 	}, nil
 }
 
@@ -126,29 +265,24 @@ func (m *Manager) GetAllContainers() ([]*Container, error) {
 	return containers, nil
 }
 
+// Name of the pod of a project
+func podName(user, project string) string {
+	return PREFIX + user + "-" + project
+}
+
+// Name of the container of an image in a project's pod
+func containerName(user, project, shortName string) string {
+	return podName(user, project) + "-" + shortName
+}
+
 func (m *Manager) PodExists(user, project string) (bool, error) {
-	exists, err := pods.Exists(*m.ctx, PREFIX+user+"-"+project, nil)
+	exists, err := pods.Exists(*m.ctx, podName(user, project), nil)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if container exists: %w", err)
 	}
 	return exists, nil
 }
 
-// Check image is allowed and pull it if not exists locally
-func (m *Manager) PullImageIfNotExists(image string) error {
-	exists, err := images.Exists(*m.ctx, image, nil)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return nil
-	}
-	quiet := true
-	r, err := images.Pull(*m.ctx, image, &images.PullOptions{Quiet: &quiet})
-	m.log.Println("INFO: PullImageIfNotExists", r, err)
-	return err
-}
-
 // Get a container by name of user and project
 // might return nil
 func (m *Manager) GetContainers(user, project string) ([]*Container, error) {
@@ -179,7 +313,7 @@ func (m *Manager) GetContainers(user, project string) ([]*Container, error) {
 	return containers, nil
 }
 
-func (m Manager) toHostPath(relativePath string) string {
+func (m *Manager) toHostPath(relativePath string) string {
 	return filepath.Join(m.hostPath, m.dataPath, relativePath)
 }
 
@@ -188,47 +322,308 @@ type PodOptions struct {
 	Project string
 	// Environment variables to pass to ALL containers
 	InputEnvVars map[string]string
+	// Environment variables to pass to a single container
+	// key is the image short name, value is the env vars for that image
+	// Takes precedence over InputEnvVars
+	ImageEnvVars map[string]map[string]string
 	Runtime      runtimes.Runtime
+	// Remove images pulled by SpawnPod if it fails
+	RemovePulledImages bool
+	// When to pull images, PullMissing if empty
+	PullPolicy string
+	// Where to write pull progress, discarded if nil
+	PullProgress io.Writer
+	// Use images built by BuildRuntime instead of pulling them
+	// Mirrors, pinned digests and signatures don't apply to local images
+	LocalImages bool
+	// Date after which the pod is stopped by ExpirePods, never if zero
+	Expires time.Time
+	// Restart policy of all containers (e.g. on-failure:3), the runtime's one if empty
+	RestartPolicy string
+	// Network of the pod, the manager's one if nil
+	Network *NetworkOptions
+	// Run images the runtime can share per user in the user's shared pod, instead of the project's pod
+	SharedServices bool
 }
 
-func (m *Manager) SpawnPod(opt *PodOptions) error {
+// Check that every image referenced in ImageEnvVars exists in the runtime
+// and that images dependencies can be satisfied
+func (opt *PodOptions) Validate() error {
+	if opt.Project == SharedProject {
+		return &ErrReservedProject{Project: opt.Project}
+	}
+	for shortName := range opt.ImageEnvVars {
+		if _, exists := opt.Runtime.Images[shortName]; !exists {
+			return &ErrImageNotInRuntime{Image: shortName, Runtime: opt.Runtime.Name}
+		}
+	}
+	if _, err := opt.Runtime.StartOrder(); err != nil {
+		return err
+	}
+	if opt.RestartPolicy != "" {
+		if _, err := runtimes.ParseRestartPolicy(opt.RestartPolicy); err != nil {
+			return err
+		}
+	}
+	if opt.Network != nil {
+		if err := opt.Network.Validate(); err != nil {
+			return err
+		}
+	}
+	return ValidatePullPolicy(opt.PullPolicy)
+}
+
+// Merge global and image-specific env vars for the given image
+func (opt *PodOptions) EnvVarsFor(shortName string) map[string]string {
+	envVars := make(map[string]string, len(opt.InputEnvVars)+len(opt.ImageEnvVars[shortName]))
+	for name, value := range opt.InputEnvVars {
+		envVars[name] = value
+	}
+	for name, value := range opt.ImageEnvVars[shortName] {
+		envVars[name] = value
+	}
+	return envVars
+}
+
+// Create a project's pod and its containers
+// On failure, everything created is reverted and rollback failures are joined to the returned error
+func (m *Manager) SpawnPod(opt *PodOptions) (err error) {
+	defer func() {
+		m.audit(audit.Entry{
+			Action:  ActionSpawn,
+			User:    opt.User,
+			Project: opt.Project,
+			Runtime: opt.Runtime.Name,
+			EnvVars: envVarNames(opt.InputEnvVars, opt.ImageEnvVars),
+		}, err)
+	}()
+
+	if err := opt.Validate(); err != nil {
+		return err
+	}
+
+	resolved := *opt
+	if opt.LocalImages {
+		resolved.Runtime = opt.Runtime.WithLocalImages()
+		resolved.PullPolicy = PullNever
+	} else {
+		resolved.Runtime = opt.Runtime.WithMirrors(m.mirrors)
+	}
+	if opt.RestartPolicy != "" {
+		// already validated
+		policy, _ := runtimes.ParseRestartPolicy(opt.RestartPolicy)
+		resolved.Runtime = resolved.Runtime.WithRestartPolicy(policy)
+	}
+	if opt.Network == nil {
+		network := m.network
+		resolved.Network = &network
+	}
+	if !opt.SharedServices {
+		resolved.Runtime = resolved.Runtime.WithSharedImages(nil)
+	}
+	opt = &resolved
+
+	started := time.Now()
+	tx := &transaction{}
+	err = m.spawnPod(tx, opt)
+	m.recordSpawn(opt, started, err)
+	if err != nil {
+		m.log.Error("failed to spawn pod, rolling back", "user", opt.User, "project", opt.Project, "error", err)
+		err = errors.Join(err, tx.rollback())
+		if len(opt.Runtime.SharedImages()) > 0 {
+			// now that the project's pod is gone, so is its reference to shared services
+			err = errors.Join(err, m.releaseShared(opt.User), m.removeUnusedNetworks(opt.User))
+		}
+		m.notify(WebhookSpawnFailed, opt.User, opt.Project, map[string]any{"runtime": opt.Runtime.Name, "error": err.Error()})
+		return err
+	}
+	m.notify(WebhookSpawnSucceeded, opt.User, opt.Project, map[string]any{"runtime": opt.Runtime.Name})
+	// the data directory may hold the state of a previous pod of the project
+	err = m.updateState(opt.User, opt.Project, func(state *ProjectState) {
+		*state = ProjectState{LastActivity: time.Now()}
+	})
+	if err != nil {
+		m.log.Warn("failed to reset project state", "user", opt.User, "project", opt.Project, "error", err)
+	}
+	return nil
+}
+
+func (m *Manager) spawnPod(tx *transaction, opt *PodOptions) error {
 	podSpecGen := specgen.NewPodSpecGenerator()
-	podSpecGen.Name = PREFIX + opt.User + "-" + opt.Project
+	podSpecGen.Name = podName(opt.User, opt.Project)
 	podSpecGen.Labels = map[string]string{
 		L_IS_OWNED: "true",
 		L_USER:     opt.User,
 		L_PROJECT:  opt.Project,
+		L_RUNTIME:  opt.Runtime.Name,
+	}
+	if opt.LocalImages {
+		podSpecGen.Labels[L_LOCAL] = "true"
+	}
+	if !opt.Expires.IsZero() {
+		podSpecGen.Labels[L_EXPIRES] = opt.Expires.Format(expiresFormat)
+	}
+	if opt.RestartPolicy != "" {
+		podSpecGen.Labels[L_RESTART] = opt.RestartPolicy
 	}
 	podSpecGen.PortMappings = append(podSpecGen.PortMappings, types.PortMapping{ContainerPort: 80})
 
-	podSpec := entities.PodSpec{
-		PodSpecGen: *podSpecGen,
+	shared := opt.Runtime.SharedImages()
+	if len(shared) > 0 {
+		names := make([]string, len(shared))
+		for i, image := range shared {
+			names[i] = image.ShortName
+		}
+		podSpecGen.Labels[L_USES_SHARED] = strings.Join(names, ",")
 	}
 
-	podCreateResponse, err := pods.CreatePodFromSpec(*m.ctx, &podSpec)
-	if err != nil {
-		return fmt.Errorf("failed to create pod: %w", err)
+	// already validated
+	order, _ := opt.Runtime.StartOrder()
+
+	// pull everything up front, so containers are created back to back
+	imgs := make([]string, len(order))
+	for i, image := range order {
+		imgs[i] = image.Reference()
+	}
+	pullTx := tx
+	if !opt.RemovePulledImages {
+		pullTx = nil
+	}
+	if err := m.pullImages(pullTx, imgs, opt.PullPolicy, opt.PullProgress); err != nil {
+		m.record(m.metrics.Inc(metricPullFailures, projectLabels(opt.User, opt.Project, opt.Runtime.Name), 1))
+		return err
 	}
 
-	m.log.Printf("INFO: Created pod %s", podCreateResponse.Id)
+	// networks and the pod are created under the lock, so destroys neither remove the networks
+	// before the pod joins them, nor miss the pod's reference to shared services
+	var podCreateResponse *entities.PodCreateReport
+	var sharedEnvVars map[string]string
+	err := m.withSharedLock(opt.User, func() error {
+		network, err := m.ensureNetwork(tx, opt.User, opt.Project, *opt.Network)
+		if err != nil {
+			return err
+		}
+		sharedNet := ""
+		if len(shared) > 0 {
+			// shared services are reached through their own network, which outlives the spawn like them
+			if sharedNet, err = m.ensureNetwork(&transaction{}, opt.User, SharedProject, sharedNetwork); err != nil {
+				return err
+			}
+		}
+		joinNetworks(podSpecGen, network, sharedNet)
 
-	for _, image := range opt.Runtime.Images {
-		err = m.SpawnContainerInPod(podCreateResponse.Id, &image, opt.InputEnvVars, fmt.Sprintf("%s-%s-%s", PREFIX+opt.User, opt.Project, image.ShortName), opt.User, opt.Project)
+		podCreateResponse, err = pods.CreatePodFromSpec(*m.ctx, &entities.PodSpec{PodSpecGen: *podSpecGen})
 		if err != nil {
+			return fmt.Errorf("failed to create pod: %w", err)
+		}
+		tx.record("create pod "+podCreateResponse.Id, func() error {
 			force := true
-			m.log.Printf("ERROR: Failed to spawn container in pod, removing pod: %s", err)
-			pods.Remove(*m.ctx, podCreateResponse.Id, &pods.RemoveOptions{Force: &force})
-			return fmt.Errorf("failed to spawn container in pod: %w", err)
+			_, err := pods.Remove(*m.ctx, podCreateResponse.Id, &pods.RemoveOptions{Force: &force})
+			return err
+		})
+
+		m.log.Info("created pod", "user", opt.User, "project", opt.Project, "pod_id", podCreateResponse.Id)
+
+		sharedEnvVars, err = m.setupShared(*m.ctx, opt, sharedNet)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(sharedEnvVars) > 0 {
+		// connection details of shared services, image-specific env vars still take precedence
+		withShared := *opt
+		withShared.InputEnvVars = make(map[string]string, len(opt.InputEnvVars)+len(sharedEnvVars))
+		for name, value := range opt.InputEnvVars {
+			withShared.InputEnvVars[name] = value
+		}
+		for name, value := range sharedEnvVars {
+			withShared.InputEnvVars[name] = value
 		}
+		opt = &withShared
+	}
+
+	err = m.spawnContainersInOrder(tx, podCreateResponse.Id, opt, withoutShared(order))
+	if err != nil {
+		return fmt.Errorf("failed to spawn container in pod: %w", err)
 	}
 
 	return nil
 }
 
+// Spawn containers concurrently, each one once its dependencies are spawned
+// order must be a topological order, so dependencies are scheduled first and
+// the concurrency limit can't be exhausted by containers waiting on unscheduled ones
+func (m *Manager) spawnContainersInOrder(tx *transaction, podID string, opt *PodOptions, order []runtimes.Image) error {
+	spawned := make(map[string]chan struct{}, len(order))
+	for _, image := range order {
+		spawned[image.ShortName] = make(chan struct{})
+	}
+
+	g, ctx := errgroup.WithContext(*m.ctx)
+	g.SetLimit(m.concurrency)
+	for _, image := range order {
+		image := image
+		g.Go(func() error {
+			for _, dep := range image.DependsOn {
+				select {
+				case <-spawned[dep.Image]:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if err := m.waitDependencies(ctx, opt.User, opt.Project, image); err != nil {
+				return err
+			}
+			err := m.spawnContainerInPod(tx, podID, &image, opt.EnvVarsFor(image.ShortName), containerName(opt.User, opt.Project, image.ShortName), opt.User, opt.Project)
+			if err != nil {
+				return fmt.Errorf("%s: %w", image.ShortName, err)
+			}
+			close(spawned[image.ShortName])
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// Create and start a container in an existing pod
+// On failure, everything created is reverted and rollback failures are joined to the returned error
 func (m *Manager) SpawnContainerInPod(podID string, img *runtimes.Image, inputEnvVar map[string]string, containerName string, user string, project string) error {
-	err := m.PullImageIfNotExists(img.FullyQualifiedName)
+	tx := &transaction{}
+	err := m.spawnContainerInPod(tx, podID, img, inputEnvVar, containerName, user, project)
 	if err != nil {
-		return fmt.Errorf("failed to pull image: %w", err)
+		m.log.Error("failed to spawn container, rolling back", "user", user, "project", project, "pod_id", podID, "container", containerName, "error", err)
+		return errors.Join(err, tx.rollback())
+	}
+	return nil
+}
+
+func (m *Manager) spawnContainerInPod(tx *transaction, podID string, img *runtimes.Image, inputEnvVar map[string]string, containerName string, user string, project string) error {
+	id, err := m.createContainerInPod(tx, podID, img, inputEnvVar, containerName, user, project)
+	if err != nil {
+		return err
+	}
+	if err := containers.Start(*m.ctx, id, nil); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	return nil
+}
+
+// Create a container in an existing pod without starting it, returning its ID
+func (m *Manager) createContainerInPod(tx *transaction, podID string, img *runtimes.Image, inputEnvVar map[string]string, containerName string, user string, project string) (string, error) {
+	err := m.PullImageIfNotExists(img.Reference())
+	if err != nil {
+		return "", fmt.Errorf("failed to pull image: %w", err)
+	}
+	if img.Tag != runtimes.LocalTag {
+		if err := m.checkPinnedDigest(img); err != nil {
+			return "", err
+		}
+		if err := m.verifySignature(img.Reference()); err != nil {
+			return "", err
+		}
 	}
 
 	relativeProjectDir := filepath.Join(user, project)
@@ -236,7 +631,7 @@ func (m *Manager) SpawnContainerInPod(podID string, img *runtimes.Image, inputEn
 	// create specs with project directory
 	spec, err := img.ToContainerSpec(m.toHostPath(relativeProjectDir), inputEnvVar)
 	if err != nil {
-		return fmt.Errorf("failed to create container spec: %w", err)
+		return "", fmt.Errorf("failed to create container spec: %w", err)
 	}
 	spec.Pod = podID
 	spec.Name = containerName
@@ -246,35 +641,147 @@ func (m *Manager) SpawnContainerInPod(podID string, img *runtimes.Image, inputEn
 		L_PROJECT:  project,
 	}
 
-	// be sure that all mounts are created
+	// be sure that all mounts are created, only removing on rollback what this spawn created
+	// i.e. at most the project directory, never the user's directory
 	for name := range img.Mounts {
-		err := tools.EnsureDirCreated(filepath.Join(m.dataPath, relativeProjectDir, name))
+		created, err := tools.EnsureDirCreatedBelow(filepath.Join(m.dataPath, user), filepath.Join(project, name))
 		if err != nil {
-			return fmt.Errorf("failed to create dir for mount: %w", err)
+			return "", fmt.Errorf("failed to create dir for mount: %w", err)
+		}
+		if created != "" {
+			tx.record("create directory "+created, func() error {
+				return os.RemoveAll(created)
+			})
 		}
 	}
 
 	// create container
 	r, err := containers.CreateWithSpec(*m.ctx, spec, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create container: %w", err)
+		return "", fmt.Errorf("failed to create container: %w", err)
 	}
+	tx.record("create container "+r.ID, func() error {
+		force := true
+		_, err := containers.Remove(*m.ctx, r.ID, &containers.RemoveOptions{Force: &force})
+		return err
+	})
 
-	m.log.Printf("INFO: Created container %s in pod %s", r.ID, podID)
+	m.log.Info("created container", "user", user, "project", project, "pod_id", podID, "container", containerName, "image", img.Reference())
 
 	if len(r.Warnings) > 0 {
-		m.log.Println("WARN:", r.Warnings)
+		m.log.Warn("container created with warnings", "container", containerName, "warnings", r.Warnings)
 	}
 
-	// start container
-	err = containers.Start(*m.ctx, r.ID, nil)
+	return r.ID, nil
+}
+
+// Stop all containers of a project's pod, keeping them and their data
+// The shared services pod is stopped with the last project using it, it can't be stopped directly
+func (m *Manager) StopPod(user, project string) error {
+	if project == SharedProject {
+		return &ErrReservedProject{Project: project}
+	}
+	return m.stopPod(user, project, StopReasonManual)
+}
+
+// Stop a pod and record the reason in the project state
+func (m *Manager) stopPod(user, project, reason string) (err error) {
+	defer func() {
+		m.audit(audit.Entry{Action: ActionStop, User: user, Project: project, Runtime: m.podRuntime(user, project), Detail: reason}, err)
+	}()
+
+	exists, err := m.PodExists(user, project)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ErrContainerDontExists{User: user, Project: project}
+	}
+
+	if _, err := pods.Stop(*m.ctx, podName(user, project), nil); err != nil {
+		return fmt.Errorf("failed to stop pod: %w", err)
+	}
+	m.log.Info("stopped pod", "user", user, "project", project, "reason", reason)
+	err = m.updateState(user, project, func(state *ProjectState) {
+		state.StopReason = reason
+		state.StoppedAt = time.Now()
+	})
+	if err != nil {
+		return err
+	}
+	return m.stopSharedIfUnused(user)
+}
+
+// Start all containers of a previously stopped project's pod
+func (m *Manager) StartPod(user, project string) (err error) {
+	defer func() {
+		m.audit(audit.Entry{Action: ActionStart, User: user, Project: project, Runtime: m.podRuntime(user, project)}, err)
+	}()
+
+	exists, err := m.PodExists(user, project)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return &ErrContainerDontExists{User: user, Project: project}
+	}
+
+	if err := m.startShared(user, project); err != nil {
+		return err
+	}
+	if _, err := pods.Start(*m.ctx, podName(user, project), nil); err != nil {
+		return fmt.Errorf("failed to start pod: %w", err)
+	}
+	m.log.Info("started pod", "user", user, "project", project)
+	return m.updateState(user, project, func(state *ProjectState) {
+		state.StopReason = ""
+		state.StoppedAt = time.Time{}
+		// don't reap it right away
+		state.LastActivity = time.Now()
+	})
+}
+
+// Remove a project's pod and its containers
+// The project's data directory is kept
+// The shared services pod is removed with the last project using it, it can't be destroyed directly
+func (m *Manager) DestroyPod(user, project string) (err error) {
+	// inspected before removal, for metrics and audit
+	runtime := m.podRuntime(user, project)
+	defer func() {
+		m.audit(audit.Entry{Action: ActionDestroy, User: user, Project: project, Runtime: runtime}, err)
+	}()
+
+	if project == SharedProject {
+		return &ErrReservedProject{Project: project}
+	}
+
+	exists, err := m.PodExists(user, project)
 	if err != nil {
-		m.log.Printf("ERROR: Failed to start container %s in pod %s, rolling back: %v", r.ID, podID, err)
-		force := true
-		containers.Remove(*m.ctx, r.ID, &containers.RemoveOptions{Force: &force})
 		return err
 	}
+	if !exists {
+		return &ErrContainerDontExists{User: user, Project: project}
+	}
 
+	// while the project's containers still hold its connection env vars
+	// on failure nothing is removed yet, so destroy can be retried
+	if err := m.deprovisionShared(user, project); err != nil {
+		return err
+	}
+
+	force := true
+	if _, err := pods.Remove(*m.ctx, podName(user, project), &pods.RemoveOptions{Force: &force}); err != nil {
+		return fmt.Errorf("failed to remove pod: %w", err)
+	}
+	m.log.Info("removed pod", "user", user, "project", project)
+	// the pod is gone, leftover shared services and networks are collected by GarbageCollect
+	if err := m.releaseShared(user); err != nil {
+		m.log.Warn("failed to release shared services", "user", user, "project", project, "error", err)
+	}
+	if err := m.removeUnusedNetworks(user); err != nil {
+		m.log.Warn("failed to remove networks", "user", user, "project", project, "error", err)
+	}
+	m.record(m.metrics.Inc(metricDestroys, projectLabels(user, project, runtime), 1))
 	return nil
 }
 
@@ -294,4 +801,330 @@ func (m *Manager) GetEnvVars(user, project string) (map[string]map[string]string
 	}
 
 	return envVars, nil
-}
\ No newline at end of file
+}
+
+// Get the runtime a project was spawned from
+func (m *Manager) GetRuntime(user, project string) (runtimes.Runtime, error) {
+	exists, err := m.PodExists(user, project)
+	if err != nil {
+		return runtimes.Runtime{}, err
+	}
+	if !exists {
+		return runtimes.Runtime{}, &ErrContainerDontExists{User: user, Project: project}
+	}
+
+	inspect, err := pods.Inspect(*m.ctx, podName(user, project), nil)
+	if err != nil {
+		return runtimes.Runtime{}, fmt.Errorf("failed to inspect pod: %w", err)
+	}
+
+	name := inspect.Labels[L_RUNTIME]
+	runtime, exists := runtimes.OfficialRuntimes[name]
+	if !exists {
+		return runtimes.Runtime{}, &ErrRuntimeUnknown{Runtime: name}
+	}
+	// only pods spawned with SharedServices use the shared services, others run every image themselves
+	runtime = runtime.WithSharedImages(usedShared(inspect.Labels))
+	if restart := inspect.Labels[L_RESTART]; restart != "" {
+		policy, err := runtimes.ParseRestartPolicy(restart)
+		if err != nil {
+			return runtimes.Runtime{}, err
+		}
+		runtime = runtime.WithRestartPolicy(policy)
+	}
+	if inspect.Labels[L_LOCAL] == "true" {
+		return runtime.WithLocalImages(), nil
+	}
+	return runtime.WithMirrors(m.mirrors), nil
+}
+
+// Changes to apply to the env vars of a running project
+type EnvVarChanges struct {
+	Set   map[string]string
+	Unset []string
+}
+
+// Update env vars of a project's containers, recreating them inside the existing pod
+// If image is empty, changes are applied to all containers of the runtime
+// Mounts are preserved as the project directory doesn't change
+func (m *Manager) UpdateEnvVars(user, project, image string, changes EnvVarChanges) (err error) {
+	defer func() {
+		entry := audit.Entry{Action: ActionUpdateEnv, User: user, Project: project, Runtime: m.podRuntime(user, project)}
+		entry.EnvVars = append(envVarNames(changes.Set, nil), changes.Unset...)
+		if image != "" {
+			entry.Detail = "image " + image
+		}
+		m.audit(entry, err)
+	}()
+
+	runtime, err := m.GetRuntime(user, project)
+	if err != nil {
+		return err
+	}
+
+	toUpdate := runtime.Images
+	if image != "" {
+		img, exists := runtime.Images[image]
+		if !exists {
+			return &ErrImageNotInRuntime{Image: image, Runtime: runtime.Name}
+		}
+		if img.Shared {
+			return &ErrSharedImage{Image: image}
+		}
+		toUpdate = map[string]runtimes.Image{image: img}
+	}
+
+	for _, img := range toUpdate {
+		if img.Shared {
+			continue
+		}
+		if err := m.recreateWithEnvVars(user, project, img, changes); err != nil {
+			return fmt.Errorf("failed to update env vars of %s: %w", img.ShortName, err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) recreateWithEnvVars(user, project string, img runtimes.Image, changes EnvVarChanges) error {
+	name := containerName(user, project, img.ShortName)
+	inspect, err := containers.Inspect(*m.ctx, name, nil)
+	if err != nil {
+		return err
+	}
+	imageInspect, err := images.GetImage(*m.ctx, inspect.Image, nil)
+	if err != nil {
+		return err
+	}
+
+	current := inputEnvVars(inspect.Config.Env, imageInspect.Config.Env)
+	updated := make(map[string]string, len(current)+len(changes.Set))
+	for k, v := range current {
+		updated[k] = v
+	}
+	for k, v := range changes.Set {
+		updated[k] = v
+	}
+	for _, k := range changes.Unset {
+		delete(updated, k)
+	}
+
+	// Resolve modifiers before removing anything, so the container is kept on invalid input
+	spec, err := img.ToContainerSpec(m.toHostPath(filepath.Join(user, project)), updated)
+	if err != nil {
+		return fmt.Errorf("failed to create container spec: %w", err)
+	}
+
+	force := true
+	if _, err := containers.Remove(*m.ctx, inspect.ID, &containers.RemoveOptions{Force: &force}); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	m.log.Info("removed container to update env vars", "user", user, "project", project, "container", name)
+
+	// keep the container's state, e.g. a project stopped as idle stays stopped
+	running := inspect.State.Running
+	err = m.recreateContainer(inspect.Pod, &img, spec.Env, name, user, project, running)
+	if err != nil {
+		m.log.Error("failed to recreate container, restoring previous env vars", "user", user, "project", project, "container", name, "error", err)
+		if rollbackErr := m.recreateContainer(inspect.Pod, &img, current, name, user, project, running); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to restore container: %w", rollbackErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// Create a container in an existing pod, starting it only if start is set
+// On failure, everything created is reverted and rollback failures are joined to the returned error
+func (m *Manager) recreateContainer(podID string, img *runtimes.Image, inputEnvVar map[string]string, containerName, user, project string, start bool) error {
+	tx := &transaction{}
+	id, err := m.createContainerInPod(tx, podID, img, inputEnvVar, containerName, user, project)
+	if err == nil && start {
+		if err = containers.Start(*m.ctx, id, nil); err != nil {
+			err = fmt.Errorf("failed to start container: %w", err)
+		}
+	}
+	if err != nil {
+		return errors.Join(err, tx.rollback())
+	}
+	return nil
+}
+
+// Env vars set on a container, not coming from its image nor added by podman
+func inputEnvVars(containerEnv, imageEnv []string) map[string]string {
+	fromImage := make(map[string]string, len(imageEnv))
+	for _, e := range imageEnv {
+		if k, v, found := strings.Cut(e, "="); found {
+			fromImage[k] = v
+		}
+	}
+
+	env := make(map[string]string)
+	for _, e := range containerEnv {
+		k, v, found := strings.Cut(e, "=")
+		if !found {
+			continue
+		}
+		if imageValue, exists := fromImage[k]; exists && imageValue == v {
+			continue
+		}
+		switch k {
+		case "container", "HOSTNAME", "HOME", "TERM":
+			if _, exists := fromImage[k]; !exists {
+				continue
+			}
+		}
+		env[k] = v
+	}
+	return env
+}
+
+// Interval between two readiness checks in WaitReady
+const readyPollInterval = time.Second
+
+// Block until every container of a project is running and healthy, and the published port answers HTTP
+// Healthchecks are run on each poll, so it doesn't depend on podman's healthcheck timers
+func (m *Manager) WaitReady(user, project string, timeout time.Duration) error {
+	runtime, err := m.GetRuntime(user, project)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		reason, err := m.notReadyReason(user, project, runtime)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &ErrNotReady{User: user, Project: project, Reason: reason}
+		}
+		m.log.Info("waiting for pod", "user", user, "project", project, "reason", reason)
+		time.Sleep(readyPollInterval)
+	}
+}
+
+// Maximum time to wait for a dependency to become healthy
+const dependencyTimeout = 2 * time.Minute
+
+// Block until the dependencies of an image with the healthy condition are healthy
+// Dependencies are expected to be already started
+func (m *Manager) waitDependencies(ctx context.Context, user, project string, img runtimes.Image) error {
+	for _, dep := range img.DependsOn {
+		if dep.Condition != runtimes.ConditionHealthy {
+			continue
+		}
+		if err := m.waitHealthy(ctx, containerName(user, project, dep.Image)); err != nil {
+			return fmt.Errorf("dependency %s of %s: %w", dep.Image, img.ShortName, err)
+		}
+	}
+	return nil
+}
+
+// Block until a started container with a healthcheck is healthy, at most dependencyTimeout
+func (m *Manager) waitHealthy(ctx context.Context, name string) error {
+	deadline := time.Now().Add(dependencyTimeout)
+	for {
+		health, err := containers.RunHealthCheck(*m.ctx, name, nil)
+		if err != nil {
+			return fmt.Errorf("failed to run healthcheck: %w", err)
+		}
+		if health.Status == define.HealthCheckHealthy {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not healthy after %s", dependencyTimeout)
+		}
+		m.log.Info("waiting for container to be healthy", "container", name)
+		select {
+		case <-time.After(readyPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Return why a project isn't ready yet, empty if ready
+// Return an error if it can't become ready (e.g. a container exited)
+func (m *Manager) notReadyReason(user, project string, runtime runtimes.Runtime) (string, error) {
+	for _, img := range runtime.Images {
+		name := containerName(user, project, img.ShortName)
+		if img.Shared {
+			name = containerName(user, SharedProject, img.ShortName)
+		}
+		inspect, err := containers.Inspect(*m.ctx, name, nil)
+		if err != nil {
+			return "", err
+		}
+		if status := inspect.State.Status; status != "running" {
+			reason := fmt.Sprintf("container %s is %s", img.ShortName, status)
+			if containerDown(status) {
+				return "", &ErrNotReady{User: user, Project: project, Reason: reason}
+			}
+			return reason, nil
+		}
+
+		if img.Healthcheck == nil {
+			continue
+		}
+		health, err := containers.RunHealthCheck(*m.ctx, name, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to run healthcheck of %s: %w", img.ShortName, err)
+		}
+		if health.Status != define.HealthCheckHealthy {
+			return fmt.Sprintf("container %s is %s", img.ShortName, health.Status), nil
+		}
+	}
+
+	url, err := m.GetURL(user, project)
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		return "", nil
+	}
+	client := http.Client{Timeout: readyPollInterval}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Sprintf("%s doesn't answer", url), nil
+	}
+	resp.Body.Close()
+	return "", nil
+}
+
+// Whether a container in this state won't run without being started again
+// Others (e.g. created, configured, stopping) are transient while a pod starts or restarts
+func containerDown(status string) bool {
+	switch status {
+	case "exited", "stopped", "dead":
+		return true
+	}
+	return false
+}
+
+// Return the URL of the port published by a project's pod, empty if none
+func (m *Manager) GetURL(user, project string) (string, error) {
+	inspect, err := pods.Inspect(*m.ctx, podName(user, project), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect pod: %w", err)
+	}
+	if inspect.InfraConfig == nil {
+		return "", nil
+	}
+
+	return publishedURL(inspect.InfraConfig.PortBindings["80/tcp"]), nil
+}
+
+// URL of the first binding of a published port, empty if none
+func publishedURL(bindings []define.InspectHostPort) string {
+	if len(bindings) == 0 {
+		return ""
+	}
+	ip := bindings[0].HostIP
+	if ip == "" || ip == "0.0.0.0" {
+		ip = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(ip, bindings[0].HostPort) + "/"
+}
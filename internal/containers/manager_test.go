@@ -3,6 +3,7 @@ package containers_test
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 	"testing"
 
 	"github.com/sinux-l5d/studentbox/internal/containers"
@@ -28,4 +29,72 @@ func BenchmarkPodSpawn(b *testing.B) {
 		opt.Project = fmt.Sprintf("benchmark-%d", i)
 		_ = manager.SpawnPod(&opt)
 	}
-}
\ No newline at end of file
+}
+
+func BenchmarkPodSpawnParallel(b *testing.B) {
+	runtime := runtimes.OfficialRuntimes["lamp"]
+	manager, _ := newManager()
+	var i int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			opt := containers.PodOptions{
+				User:    "student",
+				Project: fmt.Sprintf("benchmark-parallel-%d", atomic.AddInt64(&i, 1)),
+				Runtime: runtime,
+			}
+			_ = manager.SpawnPod(&opt)
+		}
+	})
+}
+
+// Needs podman and the lamp images, skipped otherwise
+func TestUpdateEnvVarsKeepsStoppedProjectStopped(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns a project")
+	}
+	manager, err := newManager()
+	if err != nil {
+		t.Skipf("podman isn't available: %v", err)
+	}
+	opt := containers.PodOptions{
+		User:    "student",
+		Project: "env-stopped",
+		Runtime: runtimes.OfficialRuntimes["lamp"],
+	}
+	if err := manager.SpawnPod(&opt); err != nil {
+		t.Fatal(err)
+	}
+	defer manager.DestroyPod(opt.User, opt.Project)
+	if err := manager.StopPod(opt.User, opt.Project); err != nil {
+		t.Fatal(err)
+	}
+
+	err = manager.UpdateEnvVars(opt.User, opt.Project, "", containers.EnvVarChanges{Set: map[string]string{"FOO": "bar"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only running containers are listed
+	cs, err := manager.GetContainers(opt.User, opt.Project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cs {
+		t.Errorf("expected %s to stay stopped", c.Name)
+	}
+
+	if err := manager.StartPod(opt.User, opt.Project); err != nil {
+		t.Fatal(err)
+	}
+	envs, err := manager.GetEnvVars(opt.User, opt.Project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != len(opt.Runtime.Images) {
+		t.Errorf("expected every container to be started, got %d", len(envs))
+	}
+	for name, env := range envs {
+		if env["FOO"] != "bar" {
+			t.Errorf("expected FOO to be set in %s", name)
+		}
+	}
+}
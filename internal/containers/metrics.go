@@ -0,0 +1,140 @@
+package containers
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/sinux-l5d/studentbox/internal/metrics"
+)
+
+// Name of the metrics store in the data directory, when ManagerOptions.MetricsPath is empty
+const metricsFile = ".metrics.json"
+
+// Metrics recorded by the manager and persisted in its metrics store
+const (
+	metricSpawns        = "studentbox_spawns_total"
+	metricSpawnDuration = "studentbox_spawn_duration_seconds"
+	metricDestroys      = "studentbox_destroys_total"
+	metricPullFailures  = "studentbox_pull_failures_total"
+)
+
+func newMetricsStore(path string) *metrics.Store {
+	store := metrics.NewStore(path)
+	store.Describe(metricSpawns, "Pods spawned, by result (success or failure)")
+	store.Describe(metricSpawnDuration, "Time to spawn a pod, including pulls")
+	store.Describe(metricDestroys, "Pods destroyed")
+	store.Describe(metricPullFailures, "Spawns that failed to pull an image")
+	return store
+}
+
+func projectLabels(user, project, runtime string) metrics.Labels {
+	return metrics.Labels{"user": user, "project": project, "runtime": runtime}
+}
+
+// Record a metric, failing to do so only logs a warning as metrics aren't worth failing an operation
+func (m *Manager) record(err error) {
+	if err != nil {
+		m.log.Warn("failed to record metric", "error", err)
+	}
+}
+
+func (m *Manager) recordSpawn(opt *PodOptions, started time.Time, err error) {
+	labels := projectLabels(opt.User, opt.Project, opt.Runtime.Name)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.record(m.metrics.Inc(metricSpawns, labels.With("result", result), 1))
+	if err == nil {
+		m.record(m.metrics.Observe(metricSpawnDuration, labels, time.Since(started).Seconds(), metrics.DurationBuckets))
+	}
+}
+
+// Gather metrics of all pods: state, resource and disk usage of running ones, and recorded counters
+// Metrics are labelled with the user, project and runtime of the pod
+func (m *Manager) Collect() ([]*metrics.Family, error) {
+	podList, err := pods.List(*m.ctx, &pods.ListOptions{
+		Filters: map[string][]string{"label": {L_IS_OWNED + "=true"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	runtimeOf := make(map[string]string, len(podList))
+	for _, pod := range podList {
+		runtimeOf[podName(pod.Labels[L_USER], pod.Labels[L_PROJECT])] = pod.Labels[L_RUNTIME]
+	}
+
+	state := metrics.NewFamily("studentbox_container_state", "Always 1, the state label is the container state", metrics.Gauge)
+	all := true
+	cs, err := containers.List(*m.ctx, &containers.ListOptions{
+		All:     &all,
+		Filters: map[string][]string{"label": {L_IS_OWNED + "=true"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, c := range cs {
+		user, project := c.Labels[L_USER], c.Labels[L_PROJECT]
+		labels := projectLabels(user, project, runtimeOf[podName(user, project)]).With("container", c.Names[0])
+		state.Add(1, labels.With("state", c.State))
+	}
+
+	cpu := metrics.NewFamily("studentbox_container_cpu_percent", "CPU usage of running containers", metrics.Gauge)
+	memory := metrics.NewFamily("studentbox_container_memory_bytes", "Memory usage of running containers", metrics.Gauge)
+	stats, err := m.Stats("", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range stats {
+		labels := projectLabels(pod.User, pod.Project, runtimeOf[pod.Name])
+		for _, c := range pod.Containers {
+			cpu.Add(c.CPU, labels.With("container", c.Name))
+			memory.Add(float64(c.MemUsage), labels.With("container", c.Name))
+		}
+	}
+
+	disk := metrics.NewFamily("studentbox_project_disk_bytes", "Size of the project's data directory", metrics.Gauge)
+	for _, pod := range podList {
+		user, project := pod.Labels[L_USER], pod.Labels[L_PROJECT]
+		size, err := dirSize(filepath.Join(m.dataPath, user, project))
+		if err != nil {
+			m.log.Warn("failed to get disk usage", "user", user, "project", project, "error", err)
+			continue
+		}
+		disk.Add(float64(size), projectLabels(user, project, pod.Labels[L_RUNTIME]))
+	}
+
+	recorded, err := m.metrics.Families()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics store: %w", err)
+	}
+	return append([]*metrics.Family{state, cpu, memory, disk}, recorded...), nil
+}
+
+// Apparent size of the regular files in a directory, 0 if it doesn't exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
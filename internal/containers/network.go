@@ -0,0 +1,187 @@
+package containers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containers/common/libnetwork/types"
+	"github.com/containers/podman/v4/pkg/bindings/network"
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/containers/podman/v4/pkg/specgen"
+)
+
+// Which pods share a network
+const (
+	// Podman's default network, every pod can reach every other pod
+	NetworkShared = "shared"
+	// One network per user, shared by all their projects
+	NetworkUser = "user"
+	// One network per project
+	NetworkProject = "project"
+)
+
+// Network of a project's pod
+// The zero value is podman's default network
+type NetworkOptions struct {
+	// NetworkShared, NetworkUser or NetworkProject, NetworkShared if empty
+	Scope string
+	// Block traffic between this network and other isolated networks
+	// Without it, pods of other users can still be reached through their IP
+	Isolate bool
+	// Don't route traffic outside of the network (e.g. for exams)
+	NoInternet bool
+}
+
+// Check the scope is known and options apply to it
+func (opt NetworkOptions) Validate() error {
+	switch opt.Scope {
+	case "", NetworkShared:
+		if opt.Isolate || opt.NoInternet {
+			return &ErrInvalidNetwork{Reason: "isolation and no internet require a user or project network"}
+		}
+	case NetworkUser, NetworkProject:
+	default:
+		return &ErrInvalidNetwork{Reason: fmt.Sprintf("unknown scope \"%s\", expected shared, user or project", opt.Scope)}
+	}
+	return nil
+}
+
+// Name of the dedicated network of a project, empty for the shared network
+func networkName(user, project string, opt NetworkOptions) string {
+	switch opt.Scope {
+	case NetworkUser:
+		return PREFIX + user + "-net"
+	case NetworkProject:
+		return podName(user, project) + "-net"
+	}
+	return ""
+}
+
+// Create the dedicated network of a project if it doesn't exist yet
+// Return its name, empty for the shared network
+func (m *Manager) ensureNetwork(tx *transaction, user, project string, opt NetworkOptions) (string, error) {
+	name := networkName(user, project, opt)
+	if name == "" {
+		return "", nil
+	}
+
+	exists, err := network.Exists(*m.ctx, name, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if network exists: %w", err)
+	}
+	if exists {
+		existing, err := network.Inspect(*m.ctx, name, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect network: %w", err)
+		}
+		if err := checkNetworkOptions(existing, opt); err != nil {
+			return "", err
+		}
+	} else {
+		net := types.Network{
+			Name:       name,
+			Driver:     types.BridgeNetworkDriver,
+			DNSEnabled: true,
+			Internal:   opt.NoInternet,
+			Labels: map[string]string{
+				L_IS_OWNED: "true",
+				L_USER:     user,
+			},
+			Options: map[string]string{},
+		}
+		if opt.Scope == NetworkProject {
+			net.Labels[L_PROJECT] = project
+		}
+		if opt.Isolate {
+			net.Options[types.IsolateOption] = "true"
+		}
+		if _, err := network.Create(*m.ctx, &net); err != nil {
+			return "", fmt.Errorf("failed to create network: %w", err)
+		}
+		tx.record("create network "+name, func() error {
+			_, err := network.Remove(*m.ctx, name, nil)
+			return err
+		})
+		m.log.Info("created network", "user", user, "project", project, "network", name)
+	}
+
+	return name, nil
+}
+
+// Check an existing network has the options a pod expects
+func checkNetworkOptions(existing types.Network, opt NetworkOptions) error {
+	isolated := existing.Options[types.IsolateOption] == "true"
+	if existing.Internal != opt.NoInternet || isolated != opt.Isolate {
+		return &ErrNetworkConflict{
+			Network: existing.Name,
+			Reason:  fmt.Sprintf("it exists with isolate=%t and no internet=%t", isolated, existing.Internal),
+		}
+	}
+	return nil
+}
+
+// Join a pod to the given networks instead of podman's default network
+// Empty names, i.e. the shared network, are ignored
+func joinNetworks(podSpecGen *specgen.PodSpecGenerator, names ...string) {
+	networks := make(map[string]types.PerNetworkOptions, len(names))
+	for _, name := range names {
+		if name != "" {
+			networks[name] = types.PerNetworkOptions{}
+		}
+	}
+	if len(networks) == 0 {
+		return
+	}
+	podSpecGen.NetNS = specgen.Namespace{NSMode: specgen.Bridge}
+	podSpecGen.Networks = networks
+}
+
+// Networks created by studentbox that no pod is connected to
+// filters are podman's network filters (e.g. label)
+func (m *Manager) unusedNetworks(filters map[string][]string, cutoff time.Time) ([]types.Network, error) {
+	nets, err := network.List(*m.ctx, &network.ListOptions{Filters: filters})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	list, err := pods.List(*m.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	used := make(map[string]struct{})
+	for _, pod := range list {
+		for _, name := range pod.Networks {
+			used[name] = struct{}{}
+		}
+	}
+
+	unused := make([]types.Network, 0)
+	for _, net := range nets {
+		if net.Labels[L_IS_OWNED] != "true" || net.Created.After(cutoff) {
+			continue
+		}
+		if _, ok := used[net.Name]; !ok {
+			unused = append(unused, net)
+		}
+	}
+	return unused, nil
+}
+
+// Remove the networks of a user no pod is connected to anymore
+// Spawns create networks right before their pod under the same lock, so even new ones can be removed
+func (m *Manager) removeUnusedNetworks(user string) error {
+	return m.withSharedLock(user, func() error {
+		nets, err := m.unusedNetworks(map[string][]string{
+			"label": {L_IS_OWNED + "=true", L_USER + "=" + user},
+		}, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, net := range nets {
+			if _, err := network.Remove(*m.ctx, net.Name, nil); err != nil {
+				return fmt.Errorf("failed to remove network %s: %w", net.Name, err)
+			}
+			m.log.Info("removed network", "user", user, "network", net.Name)
+		}
+		return nil
+	})
+}
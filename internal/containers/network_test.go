@@ -0,0 +1,75 @@
+package containers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/containers/common/libnetwork/types"
+)
+
+func TestNetworkOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     NetworkOptions
+		network string
+		valid   bool
+	}{
+		{"default", NetworkOptions{}, "", true},
+		{"shared", NetworkOptions{Scope: NetworkShared}, "", true},
+		{"per user", NetworkOptions{Scope: NetworkUser, Isolate: true}, "sb-alice-net", true},
+		{"per project", NetworkOptions{Scope: NetworkProject, NoInternet: true}, "sb-alice-blog-net", true},
+		{"isolated shared", NetworkOptions{Isolate: true}, "", false},
+		{"exam on shared", NetworkOptions{Scope: NetworkShared, NoInternet: true}, "", false},
+		{"unknown scope", NetworkOptions{Scope: "host"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt.Validate()
+			if tt.valid && err != nil {
+				t.Fatalf("expected valid options, got %v", err)
+			}
+			if !tt.valid {
+				var invalid *ErrInvalidNetwork
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected ErrInvalidNetwork, got %v", err)
+				}
+				return
+			}
+			if got := networkName("alice", "blog", tt.opt); got != tt.network {
+				t.Errorf("expected network %q, got %q", tt.network, got)
+			}
+		})
+	}
+}
+
+// Network as ensureNetwork creates it
+func createdNetwork(name string, opt NetworkOptions) types.Network {
+	net := types.Network{Name: name, Internal: opt.NoInternet, Options: map[string]string{}}
+	if opt.Isolate {
+		net.Options[types.IsolateOption] = "true"
+	}
+	return net
+}
+
+func TestSharedNetwork(t *testing.T) {
+	if err := sharedNetwork.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	name := networkName("alice", SharedProject, sharedNetwork)
+	if name != "sb-alice-shared-net" || name == networkName("alice", "blog", NetworkOptions{Scope: NetworkUser}) {
+		t.Fatalf("expected a network of its own, got %s", name)
+	}
+
+	// the first project sharing services spawned without internet, the second one with
+	existing := createdNetwork(name, sharedNetwork)
+	if err := checkNetworkOptions(existing, sharedNetwork); err != nil {
+		t.Errorf("expected projects with any network options to share services, got %v", err)
+	}
+
+	// their own user network does conflict, as its options come from the projects
+	user := createdNetwork("sb-alice-net", NetworkOptions{Scope: NetworkUser, NoInternet: true})
+	var conflict *ErrNetworkConflict
+	if err := checkNetworkOptions(user, NetworkOptions{Scope: NetworkUser}); !errors.As(err, &conflict) {
+		t.Errorf("expected ErrNetworkConflict, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package containers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"golang.org/x/sync/errgroup"
+)
+
+// Pull policies, matching podman's
+const (
+	// Pull only if the image doesn't exist locally
+	PullMissing = "missing"
+	// Always pull, even if the image exists locally
+	PullAlways = "always"
+	// Never pull, fail if the image doesn't exist locally
+	PullNever = "never"
+	// Pull if the registry has a newer image than the local one
+	PullNewer = "newer"
+)
+
+func ValidatePullPolicy(policy string) error {
+	switch policy {
+	case "", PullMissing, PullAlways, PullNever, PullNewer:
+		return nil
+	}
+	return fmt.Errorf("invalid pull policy %q, must be one of %s, %s, %s, %s", policy, PullMissing, PullAlways, PullNever, PullNewer)
+}
+
+// Check image is allowed and pull it if not exists locally
+// Concurrent calls for the same image share a single pull
+func (m *Manager) PullImageIfNotExists(image string) error {
+	_, err := m.pullImage(image, PullMissing, nil)
+	return err
+}
+
+// Pull all images in parallel according to the pull policy
+// Progress of each image is written to progress prefixed by the image name, if not nil
+func (m *Manager) PullImages(imgs []string, policy string, progress io.Writer) error {
+	return m.pullImages(nil, imgs, policy, progress)
+}
+
+// Images that didn't exist locally before are recorded in tx if not nil
+func (m *Manager) pullImages(tx *transaction, imgs []string, policy string, progress io.Writer) error {
+	var mu sync.Mutex
+	g := new(errgroup.Group)
+	g.SetLimit(m.concurrency)
+	for _, image := range imgs {
+		image := image
+		g.Go(func() error {
+			var w *prefixWriter
+			if progress != nil {
+				w = &prefixWriter{mu: &mu, out: progress, prefix: image + ": "}
+			}
+			pulled, err := m.pullImage(image, policy, w)
+			if err != nil {
+				return fmt.Errorf("failed to pull image %s: %w", image, err)
+			}
+			if pulled && tx != nil {
+				tx.record("pull image "+image, func() error {
+					_, errs := images.Remove(*m.ctx, []string{image}, nil)
+					return errors.Join(errs...)
+				})
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// Check if the image can be pulled according to AllowedImages
+func (m *Manager) isImageAllowed(image string) bool {
+	if len(m.allowedImages) == 0 {
+		return true
+	}
+	for _, allowed := range m.allowedImages {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if image == allowed || strings.HasPrefix(image, allowed+"/") || strings.HasPrefix(image, allowed+":") || strings.HasPrefix(image, allowed+"@") {
+			return true
+		}
+	}
+	return false
+}
+
+// Return true if the image didn't exist locally and was pulled
+func (m *Manager) pullImage(image, policy string, progress *prefixWriter) (bool, error) {
+	if !m.isImageAllowed(image) {
+		return false, &ErrImageNotAllowed{Image: image}
+	}
+	if policy == "" {
+		policy = PullMissing
+	}
+
+	pulled, err, _ := m.pulls.Do(policy+"/"+image, func() (interface{}, error) {
+		exists, err := images.Exists(*m.ctx, image, nil)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case policy == PullNever && !exists:
+			return false, &ErrImageNotPresent{Image: image}
+		case policy == PullNever, policy == PullMissing && exists:
+			return false, nil
+		}
+
+		options := &images.PullOptions{Policy: &policy}
+		if m.authFile != "" {
+			options.Authfile = &m.authFile
+		}
+		if progress == nil {
+			quiet := true
+			options.Quiet = &quiet
+		} else {
+			var w io.Writer = progress
+			options.ProgressWriter = &w
+		}
+
+		start := time.Now()
+		r, err := images.Pull(*m.ctx, image, options)
+		if err != nil {
+			m.log.Error("failed to pull image", "image", image, "policy", policy, "error", err)
+		} else {
+			m.log.Info("pulled image", "image", image, "policy", policy, "image_ids", r)
+		}
+		if progress != nil {
+			progress.Flush()
+			if err == nil {
+				fmt.Fprintf(progress, "pulled in %s\n", time.Since(start).Round(time.Millisecond))
+			}
+		}
+		return !exists && err == nil, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return pulled.(bool), nil
+}
+
+// Writer prefixing each line, so that concurrent pulls can share an output
+type prefixWriter struct {
+	// Shared by all writers of the same output
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Write the incomplete last line, if any
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(append([]byte(w.prefix), line...))
+	return err
+}
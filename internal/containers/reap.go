@@ -0,0 +1,78 @@
+package containers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+)
+
+// Outcome of ReapIdle for a running pod
+type ReapResult struct {
+	User         string
+	Project      string
+	LastActivity time.Time
+	// The pod was idle for longer than the TTL
+	Idle bool
+	// The pod was stopped, false on dry run or error
+	Stopped bool
+	Error   error
+}
+
+// Stop running pods without network activity for longer than ttl
+// Activity is measured with the network counters of the pods' containers: a pod is
+// active if they changed since the previous call, so ReapIdle must be called periodically
+// The reason is recorded in the project state, see GetState
+func (m *Manager) ReapIdle(ttl time.Duration, dryRun bool) ([]ReapResult, error) {
+	list, err := pods.List(*m.ctx, &pods.ListOptions{
+		Filters: map[string][]string{
+			"label":  {L_IS_OWNED + "=true"},
+			"status": {"running"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	now := time.Now()
+	results := make([]ReapResult, 0, len(list))
+	for _, pod := range list {
+		// shared services have no activity of their own, they are stopped with the last project using them
+		if pod.Labels[L_SHARED] == "true" {
+			continue
+		}
+		result := ReapResult{User: pod.Labels[L_USER], Project: pod.Labels[L_PROJECT]}
+
+		stats, err := m.Stats(result.User, result.Project)
+		if err != nil {
+			result.Error = err
+			results = append(results, result)
+			continue
+		}
+		var bytes uint64
+		if len(stats) > 0 {
+			bytes = stats[0].NetInput + stats[0].NetOutput
+		}
+
+		err = m.updateState(result.User, result.Project, func(state *ProjectState) {
+			if state.LastActivity.IsZero() || bytes != state.NetBytes {
+				state.LastActivity = now
+				state.NetBytes = bytes
+			}
+			result.LastActivity = state.LastActivity
+		})
+		if err != nil {
+			result.Error = err
+			results = append(results, result)
+			continue
+		}
+
+		result.Idle = now.Sub(result.LastActivity) > ttl
+		if result.Idle && !dryRun {
+			result.Error = m.stopPod(result.User, result.Project, StopReasonIdle)
+			result.Stopped = result.Error == nil
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,504 @@
+package containers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/containers/podman/v4/pkg/api/handlers"
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+	"github.com/containers/podman/v4/pkg/bindings/pods"
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/containers/podman/v4/pkg/specgen"
+	docker "github.com/docker/docker/api/types"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+	"github.com/sinux-l5d/studentbox/internal/tools"
+)
+
+// Project of the pod running a user's shared services, i.e. sb-<user>-shared
+// No project can be spawned with this name
+const SharedProject = "shared"
+
+// Prefix of the project's env vars given to provision commands
+const provisionEnvPrefix = "STUDENTBOX_ENV_"
+
+// Serializes shared services changes of a user, in the user's data directory
+const sharedLockFile = ".shared.lock"
+
+// Env var holding the host of a shared service (e.g. STUDENTBOX_MYSQL_HOST)
+func sharedHostEnvVar(shortName string) string {
+	return "STUDENTBOX_" + strings.ToUpper(strings.ReplaceAll(shortName, "-", "_")) + "_HOST"
+}
+
+// Shared images a project's pod uses, from its L_USES_SHARED label
+func usedShared(labels map[string]string) []string {
+	if labels[L_USES_SHARED] == "" {
+		return nil
+	}
+	return strings.Split(labels[L_USES_SHARED], ",")
+}
+
+// Network of the shared services of a user, sb-<user>-shared-net, joined by the projects using them
+// Its options are fixed, whatever the projects network options: isolated from other users, and
+// without internet, as projects without internet mustn't get it through there
+var sharedNetwork = NetworkOptions{Scope: NetworkProject, Isolate: true, NoInternet: true}
+
+// Run f holding the lock of a user's shared services
+// References are counted from pods labels, so spawns and destroys must not interleave
+func (m *Manager) withSharedLock(user string, f func() error) error {
+	dir := filepath.Join(m.dataPath, user)
+	if err := tools.EnsureDirCreated(dir); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, sharedLockFile), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return f()
+}
+
+// Start the shared services of a project and provision it in each of them
+// Return env vars to pass to the project's containers
+// Shared resources outlive the spawn, so they aren't rolled back with it: on failure, unused
+// ones are removed by releaseShared, and their data directory is always kept
+func (m *Manager) setupShared(ctx context.Context, opt *PodOptions, network string) (map[string]string, error) {
+	envVars := make(map[string]string)
+	shared := opt.Runtime.SharedImages()
+	if len(shared) == 0 {
+		return envVars, nil
+	}
+
+	podID, err := m.ensureSharedPod(opt.User, network)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range shared {
+		name := containerName(opt.User, SharedProject, image.ShortName)
+		if err := m.ensureSharedContainer(ctx, podID, image, name, opt.User); err != nil {
+			return nil, fmt.Errorf("shared %s: %w", image.ShortName, err)
+		}
+		provisioned, err := m.provision(ctx, name, image, opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to provision project in shared %s: %w", image.ShortName, err)
+		}
+		for key, value := range provisioned {
+			envVars[key] = value
+		}
+		envVars[sharedHostEnvVar(image.ShortName)] = podName(opt.User, SharedProject)
+	}
+	return envVars, nil
+}
+
+// Create the pod of a user's shared services if it doesn't exist yet
+func (m *Manager) ensureSharedPod(user, network string) (string, error) {
+	name := podName(user, SharedProject)
+	exists, err := pods.Exists(*m.ctx, name, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if shared pod exists: %w", err)
+	}
+	if exists {
+		inspect, err := pods.Inspect(*m.ctx, name, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect shared pod: %w", err)
+		}
+		return inspect.ID, nil
+	}
+
+	podSpecGen := specgen.NewPodSpecGenerator()
+	podSpecGen.Name = name
+	podSpecGen.Labels = map[string]string{
+		L_IS_OWNED: "true",
+		L_USER:     user,
+		L_PROJECT:  SharedProject,
+		L_SHARED:   "true",
+	}
+	joinNetworks(podSpecGen, network)
+	r, err := pods.CreatePodFromSpec(*m.ctx, &entities.PodSpec{PodSpecGen: *podSpecGen})
+	if err != nil {
+		return "", fmt.Errorf("failed to create shared pod: %w", err)
+	}
+	m.log.Info("created shared pod", "user", user, "pod_id", r.Id)
+	return r.Id, nil
+}
+
+// Create or start the shared container of an image, and wait for it to be healthy
+func (m *Manager) ensureSharedContainer(ctx context.Context, podID string, image runtimes.Image, name, user string) error {
+	exists, err := containers.Exists(*m.ctx, name, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check if container exists: %w", err)
+	}
+	if !exists {
+		// env vars get their default values, e.g. a random root password
+		// the transaction is dropped, see setupShared
+		if err := m.spawnContainerInPod(&transaction{}, podID, &image, nil, name, user, SharedProject); err != nil {
+			return err
+		}
+	} else {
+		inspect, err := containers.Inspect(*m.ctx, name, nil)
+		if err != nil {
+			return err
+		}
+		if !inspect.State.Running {
+			if err := containers.Start(*m.ctx, name, nil); err != nil {
+				return fmt.Errorf("failed to start container: %w", err)
+			}
+			m.log.Info("started shared container", "user", user, "container", name)
+		}
+	}
+
+	if image.Healthcheck == nil {
+		return nil
+	}
+	return m.waitHealthy(ctx, name)
+}
+
+// Run the provision command of a shared image for a project
+// Return the KEY=VALUE lines it printed
+func (m *Manager) provision(ctx context.Context, name string, image runtimes.Image, opt *PodOptions) (map[string]string, error) {
+	envVars := make(map[string]string)
+	if len(image.Provision) == 0 {
+		return envVars, nil
+	}
+
+	var stdout bytes.Buffer
+	env := provisionEnv(opt.User, opt.Project, opt.EnvVarsFor(image.ShortName))
+	if err := m.execShared(ctx, name, image.Provision, env, &stdout); err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid output line %q, expected KEY=VALUE", line)
+		}
+		envVars[key] = value
+	}
+	m.log.Info("provisioned project in shared container", "user", opt.User, "project", opt.Project, "container", name)
+	return envVars, nil
+}
+
+// Run a command of a shared image in its container, writing its output to stdout
+// Fail if the command exits with an error, with its stderr
+func (m *Manager) execShared(ctx context.Context, name string, cmd, env []string, stdout io.Writer) error {
+	sessionID, err := containers.ExecCreate(*m.ctx, name, &handlers.ExecCreateConfig{
+		ExecConfig: docker.ExecConfig{
+			Cmd:          cmd,
+			Env:          env,
+			AttachStdout: true,
+			AttachStderr: true,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec session: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	var out, errOut io.WriteCloser = nopCloser{stdout}, nopCloser{&stderr}
+	attach := true
+	err = containers.ExecStartAndAttach(ctx, sessionID, &containers.ExecStartAndAttachOptions{
+		OutputStream: &out,
+		ErrorStream:  &errOut,
+		AttachOutput: &attach,
+		AttachError:  &attach,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", strings.Join(cmd, " "), err)
+	}
+	inspect, err := containers.ExecInspect(*m.ctx, sessionID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec session: %w", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("%s exited with code %d: %s", strings.Join(cmd, " "), inspect.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// Dump the data a project has in the shared services it uses into dir, one <image>.dump file each
+// Return the paths of the dumps
+func (m *Manager) dumpShared(user, project, dir string) ([]string, error) {
+	runtime, err := m.GetRuntime(user, project)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]runtimes.Image, 0)
+	for _, image := range runtime.SharedImages() {
+		if len(image.Dump) > 0 {
+			images = append(images, image)
+		}
+	}
+
+	dumps := make([]string, 0, len(images))
+	err = m.execForProject(user, project, images, func(name string, image runtimes.Image, env []string) error {
+		path := filepath.Join(dir, image.ShortName+".dump")
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, path)
+		err = m.execShared(*m.ctx, name, image.Dump, env, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to dump shared %s: %w", image.ShortName, err)
+		}
+		m.log.Info("dumped project from shared container", "user", user, "project", project, "container", name)
+		return nil
+	})
+	return dumps, err
+}
+
+// Remove the data a project has in the shared services it uses, before destroying it
+func (m *Manager) deprovisionShared(user, project string) error {
+	runtime, err := m.GetRuntime(user, project)
+	if err != nil {
+		return err
+	}
+	images := make([]runtimes.Image, 0)
+	for _, image := range runtime.SharedImages() {
+		if len(image.Deprovision) > 0 {
+			images = append(images, image)
+		}
+	}
+
+	return m.execForProject(user, project, images, func(name string, image runtimes.Image, env []string) error {
+		if err := m.execShared(*m.ctx, name, image.Deprovision, env, io.Discard); err != nil {
+			return fmt.Errorf("failed to deprovision project in shared %s: %w", image.ShortName, err)
+		}
+		m.log.Info("deprovisioned project in shared container", "user", user, "project", project, "container", name)
+		return nil
+	})
+}
+
+// Run f in the shared container of each image, with the env of the project's provision command
+// The shared pod is started if needed, then stopped again if no running project uses it
+func (m *Manager) execForProject(user, project string, images []runtimes.Image, f func(name string, image runtimes.Image, env []string) error) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	// connection env vars given by provision commands, as every container of the project has them
+	envVars, err := m.projectEnv(user, project)
+	if err != nil {
+		return err
+	}
+	env := provisionEnv(user, project, envVars)
+
+	err = m.withSharedLock(user, func() error {
+		if _, err := pods.Start(*m.ctx, podName(user, SharedProject), nil); err != nil {
+			return fmt.Errorf("failed to start shared pod: %w", err)
+		}
+		for _, image := range images {
+			name := containerName(user, SharedProject, image.ShortName)
+			if image.Healthcheck != nil {
+				if err := m.waitHealthy(*m.ctx, name); err != nil {
+					return err
+				}
+			}
+			if err := f(name, image, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return m.stopSharedIfUnused(user)
+}
+
+// Env vars of a project's containers, even stopped ones
+// Env vars differing between containers take the value of one of them
+func (m *Manager) projectEnv(user, project string) (map[string]string, error) {
+	all := true
+	list, err := containers.List(*m.ctx, &containers.ListOptions{
+		All: &all,
+		Filters: map[string][]string{
+			"label": {L_IS_OWNED + "=true", L_USER + "=" + user, L_PROJECT + "=" + project},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	env := make(map[string]string)
+	for _, container := range list {
+		containerEnv, err := NewFromListContainer(*m.ctx, container).GetEnv()
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range containerEnv {
+			env[key] = value
+		}
+	}
+	return env, nil
+}
+
+// Env of the provision command of a project, sorted
+// The project's env vars are prefixed with provisionEnvPrefix: the command inherits the shared
+// container's env, whose defaults (e.g. MARIADB_DATABASE) must not be mistaken for the project's
+func provisionEnv(user, project string, envVars map[string]string) []string {
+	env := []string{"STUDENTBOX_USER=" + user, "STUDENTBOX_PROJECT=" + project}
+	for key, value := range envVars {
+		env = append(env, provisionEnvPrefix+key+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// Remove the shared containers of a user no project uses anymore,
+// and the shared pod once it has no container left
+func (m *Manager) releaseShared(user string) error {
+	return m.withSharedLock(user, func() error {
+		list, err := pods.List(*m.ctx, &pods.ListOptions{
+			Filters: map[string][]string{
+				"label": {L_IS_OWNED + "=true", L_USER + "=" + user},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+		used := make(map[string]bool)
+		for _, pod := range list {
+			for _, name := range usedShared(pod.Labels) {
+				used[name] = true
+			}
+		}
+
+		exists, err := m.PodExists(user, SharedProject)
+		if err != nil || !exists {
+			return err
+		}
+		// stopped ones too, the shared pod is stopped with the last running project
+		all := true
+		cs, err := containers.List(*m.ctx, &containers.ListOptions{
+			All: &all,
+			Filters: map[string][]string{
+				"label": {L_IS_OWNED + "=true", L_USER + "=" + user, L_PROJECT + "=" + SharedProject},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list shared containers: %w", err)
+		}
+		prefix := podName(user, SharedProject) + "-"
+		remaining := len(cs)
+		force := true
+		for _, container := range cs {
+			name := container.Names[0]
+			if used[strings.TrimPrefix(name, prefix)] {
+				continue
+			}
+			if _, err := containers.Remove(*m.ctx, name, &containers.RemoveOptions{Force: &force}); err != nil {
+				return fmt.Errorf("failed to remove shared container: %w", err)
+			}
+			m.log.Info("removed unused shared container", "user", user, "container", name)
+			remaining--
+		}
+		if remaining > 0 {
+			return nil
+		}
+		if _, err := pods.Remove(*m.ctx, podName(user, SharedProject), &pods.RemoveOptions{Force: &force}); err != nil {
+			return fmt.Errorf("failed to remove shared pod: %w", err)
+		}
+		m.log.Info("removed shared pod", "user", user)
+		return nil
+	})
+}
+
+// Start the shared pod of a user if the project uses shared services
+func (m *Manager) startShared(user, project string) error {
+	inspect, err := pods.Inspect(*m.ctx, podName(user, project), nil)
+	if err != nil {
+		return fmt.Errorf("failed to inspect pod: %w", err)
+	}
+	if len(usedShared(inspect.Labels)) == 0 {
+		return nil
+	}
+	if _, err := pods.Start(*m.ctx, podName(user, SharedProject), nil); err != nil {
+		return fmt.Errorf("failed to start shared pod: %w", err)
+	}
+	return nil
+}
+
+// Stop the shared pod of a user once no running project uses it
+func (m *Manager) stopSharedIfUnused(user string) error {
+	return m.withSharedLock(user, func() error {
+		list, err := pods.List(*m.ctx, &pods.ListOptions{
+			Filters: map[string][]string{
+				"label":  {L_IS_OWNED + "=true", L_USER + "=" + user},
+				"status": {"running"},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+		running, inUse := sharedInUse(list)
+		if !running || inUse {
+			return nil
+		}
+		if _, err := pods.Stop(*m.ctx, podName(user, SharedProject), nil); err != nil {
+			return fmt.Errorf("failed to stop shared pod: %w", err)
+		}
+		m.log.Info("stopped unused shared pod", "user", user)
+		return nil
+	})
+}
+
+// Whether the shared pod is among the running pods of a user, and whether a running project uses it
+func sharedInUse(running []*entities.ListPodsReport) (sharedRunning, inUse bool) {
+	for _, pod := range running {
+		if pod.Labels[L_SHARED] == "true" {
+			sharedRunning = true
+		} else if len(usedShared(pod.Labels)) > 0 {
+			inUse = true
+		}
+	}
+	return sharedRunning, inUse
+}
+
+// Images of a start order that run in the project's pod, without dependencies on shared images
+// Shared images are started and healthy before, see setupShared
+func withoutShared(order []runtimes.Image) []runtimes.Image {
+	shared := make(map[string]bool)
+	for _, image := range order {
+		if image.Shared {
+			shared[image.ShortName] = true
+		}
+	}
+	own := make([]runtimes.Image, 0, len(order))
+	for _, image := range order {
+		if image.Shared {
+			continue
+		}
+		deps := make([]runtimes.Dependency, 0, len(image.DependsOn))
+		for _, dep := range image.DependsOn {
+			if !shared[dep.Image] {
+				deps = append(deps, dep)
+			}
+		}
+		image.DependsOn = deps
+		own = append(own, image)
+	}
+	return own
+}
@@ -0,0 +1,188 @@
+package containers
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v4/pkg/domain/entities"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+func TestWithoutShared(t *testing.T) {
+	order := []runtimes.Image{
+		{ShortName: "mysql", Shared: true},
+		{ShortName: "php", DependsOn: []runtimes.Dependency{{Image: "mysql", Condition: runtimes.ConditionHealthy}}},
+		{ShortName: "apache", DependsOn: []runtimes.Dependency{{Image: "php", Condition: runtimes.ConditionStarted}}},
+	}
+	own := withoutShared(order)
+	if len(own) != 2 || own[0].ShortName != "php" || own[1].ShortName != "apache" {
+		t.Fatalf("expected php and apache, got %+v", own)
+	}
+	if len(own[0].DependsOn) != 0 {
+		t.Errorf("expected dependency on shared mysql to be dropped, got %+v", own[0].DependsOn)
+	}
+	if len(own[1].DependsOn) != 1 {
+		t.Errorf("expected dependency on php to be kept, got %+v", own[1].DependsOn)
+	}
+	if len(order[1].DependsOn) != 1 {
+		t.Errorf("start order was modified")
+	}
+}
+
+func TestSharedHostEnvVar(t *testing.T) {
+	for shortName, expected := range map[string]string{
+		"mysql":       "STUDENTBOX_MYSQL_HOST",
+		"postgres-15": "STUDENTBOX_POSTGRES_15_HOST",
+	} {
+		if got := sharedHostEnvVar(shortName); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestSharedInUse(t *testing.T) {
+	shared := &entities.ListPodsReport{Labels: map[string]string{L_SHARED: "true"}}
+	blog := &entities.ListPodsReport{Labels: map[string]string{L_USES_SHARED: "mysql"}}
+	static := &entities.ListPodsReport{Labels: map[string]string{}}
+	tests := []struct {
+		name    string
+		running []*entities.ListPodsReport
+		stop    bool
+	}{
+		{"last project stopped", []*entities.ListPodsReport{shared, static}, true},
+		{"a project still uses it", []*entities.ListPodsReport{shared, static, blog}, false},
+		{"already stopped", []*entities.ListPodsReport{blog}, false},
+		{"nothing running", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			running, inUse := sharedInUse(tt.running)
+			if got := running && !inUse; got != tt.stop {
+				t.Errorf("expected stop %t, got %t", tt.stop, got)
+			}
+		})
+	}
+}
+
+// Run LAMP's provision script with a fake mariadb client, in the env of a shared container
+// created with its image's defaults, returning its output and the SQL it sent
+func runProvision(t *testing.T, project string, envVars map[string]string) (map[string]string, string) {
+	dir := t.TempDir()
+	sqlLog := filepath.Join(dir, "sql")
+	fake := "#!/bin/sh\ncat >> \"$SQL_LOG\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "mariadb"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sh", "../../runtimes/lamp/provision.sh")
+	cmd.Env = append([]string{
+		"PATH=" + dir + ":" + os.Getenv("PATH"),
+		"SQL_LOG=" + sqlLog,
+		"MARIADB_ROOT_PASSWORD=root",
+		"MARIADB_DATABASE=app",
+		"MARIADB_USER=student",
+		"MARIADB_PASSWORD=shared",
+	}, provisionEnv("alice", project, envVars)...)
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("provision failed: %v", err)
+	}
+	provisioned := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		provisioned[key] = value
+	}
+	sql, err := os.ReadFile(sqlLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provisioned, string(sql)
+}
+
+func TestProvisionSeparatesProjects(t *testing.T) {
+	blog, _ := runProvision(t, "blog", nil)
+	shop, _ := runProvision(t, "shop", nil)
+	for _, key := range []string{"MARIADB_DATABASE", "MARIADB_USER", "MARIADB_PASSWORD"} {
+		if blog[key] == "" || blog[key] == shop[key] {
+			t.Errorf("expected projects to get different %s, got %q and %q", key, blog[key], shop[key])
+		}
+	}
+	if blog["MARIADB_DATABASE"] != "blog" || blog["MARIADB_USER"] != "blog" {
+		t.Errorf("expected the project's name as database and user, got %v", blog)
+	}
+}
+
+func TestProvisionExplicitValues(t *testing.T) {
+	provisioned, sql := runProvision(t, "blog", map[string]string{
+		"MARIADB_DATABASE": "wordpress",
+		"MARIADB_PASSWORD": `a\' OR 1=1 -- `,
+	})
+	if provisioned["MARIADB_DATABASE"] != "wordpress" || provisioned["MARIADB_USER"] != "blog" {
+		t.Errorf("expected explicit database and default user, got %v", provisioned)
+	}
+	if !strings.Contains(sql, `IDENTIFIED BY 'a\\'' OR 1=1 -- '`) {
+		t.Errorf("expected backslash and quote to be escaped, got:\n%s", sql)
+	}
+}
+
+func TestDumpUsesProjectDatabase(t *testing.T) {
+	dir := t.TempDir()
+	fake := "#!/bin/sh\necho \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "mariadb-dump"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for database, envVars := range map[string]map[string]string{
+		"blog":      nil,
+		"wordpress": {"MARIADB_DATABASE": "wordpress"},
+	} {
+		cmd := exec.Command("sh", "../../runtimes/lamp/dump.sh")
+		cmd.Env = append([]string{
+			"PATH=" + dir + ":" + os.Getenv("PATH"),
+			"MARIADB_ROOT_PASSWORD=root",
+			"MARIADB_DATABASE=app",
+		}, provisionEnv("alice", "blog", envVars)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("dump failed: %v", err)
+		}
+		if !strings.HasSuffix(strings.TrimSpace(string(out)), "--databases "+database) {
+			t.Errorf("expected a dump of %s, got %q", database, out)
+		}
+	}
+}
+
+func TestDeprovisionDropsProjectDatabase(t *testing.T) {
+	dir := t.TempDir()
+	fake := "#!/bin/sh\ncat\n"
+	if err := os.WriteFile(filepath.Join(dir, "mariadb"), []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		envVars map[string]string
+		sql     []string
+	}{
+		{nil, []string{"DROP DATABASE IF EXISTS `blog`;", "DROP USER IF EXISTS 'blog'@'%';"}},
+		{map[string]string{"MARIADB_DATABASE": "word`press", "MARIADB_USER": "o'neil"}, []string{"DROP DATABASE IF EXISTS `word``press`;", "DROP USER IF EXISTS 'o''neil'@'%';"}},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("sh", "../../runtimes/lamp/deprovision.sh")
+		cmd.Env = append([]string{
+			"PATH=" + dir + ":" + os.Getenv("PATH"),
+			"MARIADB_ROOT_PASSWORD=root",
+			"MARIADB_DATABASE=app",
+			"MARIADB_USER=student",
+		}, provisionEnv("alice", "blog", tt.envVars)...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("deprovision failed: %v", err)
+		}
+		for _, statement := range tt.sql {
+			if !strings.Contains(string(out), statement) {
+				t.Errorf("expected %q, got:\n%s", statement, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package containers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sinux-l5d/studentbox/internal/tools"
+)
+
+// Name of the state file at the root of a project's data directory
+// The project root isn't mounted in containers, only its subdirectories are
+const stateFile = ".studentbox.json"
+
+// Reasons a pod was stopped
+const (
+	StopReasonManual  = "manual"
+	StopReasonIdle    = "idle"
+	StopReasonExpired = "expired"
+)
+
+// Mutable information about a project, persisted on host as pods labels can't be updated
+type ProjectState struct {
+	// Why the pod was last stopped, empty if running
+	StopReason string    `json:"stop_reason,omitempty"`
+	StoppedAt  time.Time `json:"stopped_at,omitempty"`
+	// Last time network activity was observed by ReapIdle
+	LastActivity time.Time `json:"last_activity,omitempty"`
+	// Network bytes (in + out) observed at LastActivity
+	NetBytes uint64 `json:"net_bytes,omitempty"`
+	// Expiry date set by Extend, overrides the L_EXPIRES label
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// Extend removed the expiry date
+	NeverExpires bool `json:"never_expires,omitempty"`
+	// The expiry warning was sent for the current expiry date
+	ExpiryWarned bool `json:"expiry_warned,omitempty"`
+	// ExpirePods stopped (and snapshotted, destroyed) the project
+	Expired bool `json:"expired,omitempty"`
+}
+
+func (m *Manager) statePath(user, project string) string {
+	return filepath.Join(m.dataPath, user, project, stateFile)
+}
+
+// Get the state of a project, empty if never saved
+func (m *Manager) GetState(user, project string) (*ProjectState, error) {
+	state := &ProjectState{}
+	content, err := os.ReadFile(m.statePath(user, project))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Read, modify and write back the state of a project
+func (m *Manager) updateState(user, project string, update func(*ProjectState)) error {
+	state, err := m.GetState(user, project)
+	if err != nil {
+		return err
+	}
+	update(state)
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := m.statePath(user, project)
+	if err := tools.EnsureDirCreated(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// write then rename, so a crash never leaves a truncated state
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,127 @@
+package containers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/containers/podman/v4/pkg/bindings/containers"
+)
+
+// Resource usage of a container
+// Counters (network, block IO) are cumulative since the container started
+type ContainerStats struct {
+	Name        string  `json:"name"`
+	CPU         float64 `json:"cpu_percent"`
+	MemUsage    uint64  `json:"mem_usage"`
+	MemLimit    uint64  `json:"mem_limit"`
+	NetInput    uint64  `json:"net_input"`
+	NetOutput   uint64  `json:"net_output"`
+	BlockInput  uint64  `json:"block_input"`
+	BlockOutput uint64  `json:"block_output"`
+	PIDs        uint64  `json:"pids"`
+}
+
+// Resource usage of a project's pod, the sum of its running containers
+type PodStats struct {
+	User       string           `json:"user"`
+	Project    string           `json:"project"`
+	Containers []ContainerStats `json:"containers"`
+	ContainerStats
+}
+
+func (s *PodStats) add(c ContainerStats) {
+	s.Containers = append(s.Containers, c)
+	s.CPU += c.CPU
+	s.MemUsage += c.MemUsage
+	s.MemLimit += c.MemLimit
+	s.BlockInput += c.BlockInput
+	s.BlockOutput += c.BlockOutput
+	s.PIDs += c.PIDs
+	// containers share the pod's network namespace, they all see the same counters
+	if c.NetInput+c.NetOutput > s.NetInput+s.NetOutput {
+		s.NetInput, s.NetOutput = c.NetInput, c.NetOutput
+	}
+}
+
+// Get resource usage of running pods, sorted by user and project
+// Empty user or project match all users or projects
+func (m *Manager) Stats(user, project string) ([]PodStats, error) {
+	labels := []string{L_IS_OWNED + "=true"}
+	if user != "" {
+		labels = append(labels, L_USER+"="+user)
+	}
+	if project != "" {
+		labels = append(labels, L_PROJECT+"="+project)
+	}
+	cs, err := containers.List(*m.ctx, &containers.ListOptions{
+		Filters: map[string][]string{
+			"label":  labels,
+			"status": {"running"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	pods := make(map[string]*PodStats)
+	owners := make(map[string]string, len(cs))
+	ids := make([]string, 0, len(cs))
+	for _, c := range cs {
+		pod := podName(c.Labels[L_USER], c.Labels[L_PROJECT])
+		if _, exists := pods[pod]; !exists {
+			pods[pod] = &PodStats{
+				User:           c.Labels[L_USER],
+				Project:        c.Labels[L_PROJECT],
+				ContainerStats: ContainerStats{Name: pod},
+			}
+		}
+		owners[c.ID] = pod
+		ids = append(ids, c.ID)
+	}
+	if len(ids) == 0 {
+		return []PodStats{}, nil
+	}
+
+	stream := false
+	reports, err := containers.Stats(*m.ctx, ids, &containers.StatsOptions{Stream: &stream})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	for report := range reports {
+		if report.Error != nil {
+			return nil, fmt.Errorf("failed to get stats: %w", report.Error)
+		}
+		for _, s := range report.Stats {
+			pod, exists := pods[owners[s.ContainerID]]
+			if !exists {
+				continue
+			}
+			pod.add(ContainerStats{
+				Name:        s.Name,
+				CPU:         s.CPU,
+				MemUsage:    s.MemUsage,
+				MemLimit:    s.MemLimit,
+				NetInput:    s.NetInput,
+				NetOutput:   s.NetOutput,
+				BlockInput:  s.BlockInput,
+				BlockOutput: s.BlockOutput,
+				PIDs:        s.PIDs,
+			})
+		}
+	}
+
+	stats := make([]PodStats, 0, len(pods))
+	for _, pod := range pods {
+		sort.Slice(pod.Containers, func(i, j int) bool {
+			return pod.Containers[i].Name < pod.Containers[j].Name
+		})
+		stats = append(stats, *pod)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].User != stats[j].User {
+			return stats[i].User < stats[j].User
+		}
+		return stats[i].Project < stats[j].Project
+	})
+	return stats, nil
+}
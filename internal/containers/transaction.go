@@ -0,0 +1,44 @@
+package containers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Undo log of the side effects of an operation, to revert them on failure
+// Safe for concurrent use
+type transaction struct {
+	mu    sync.Mutex
+	steps []undoStep
+}
+
+type undoStep struct {
+	// What was done, e.g. "create pod sb-user-project"
+	description string
+	undo        func() error
+}
+
+// Record a side effect and how to revert it
+func (tx *transaction) record(description string, undo func() error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.steps = append(tx.steps, undoStep{description: description, undo: undo})
+}
+
+// Revert recorded side effects in reverse order
+// All steps are attempted, failures are joined in the returned error
+func (tx *transaction) rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	var errs []error
+	for i := len(tx.steps) - 1; i >= 0; i-- {
+		step := tx.steps[i]
+		if err := step.undo(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to rollback %s: %w", step.description, err))
+		}
+	}
+	tx.steps = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,34 @@
+package containers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTransactionRollback(t *testing.T) {
+	tx := &transaction{}
+	var order []string
+	for _, name := range []string{"pod", "dir", "container"} {
+		name := name
+		tx.record(name, func() error {
+			order = append(order, name)
+			if name == "dir" {
+				return errors.New("permission denied")
+			}
+			return nil
+		})
+	}
+
+	err := tx.rollback()
+	if strings.Join(order, ",") != "container,dir,pod" {
+		t.Errorf("Expected reverse order, got %v", order)
+	}
+	if err == nil || !strings.Contains(err.Error(), "dir: permission denied") {
+		t.Errorf("Expected rollback failure to be reported, got %v", err)
+	}
+
+	if err := tx.rollback(); err != nil {
+		t.Errorf("Expected second rollback to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package containers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/containers/podman/v4/pkg/bindings/images"
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+// Check the local image matches the digest pinned in the runtime, if any
+func (m *Manager) checkPinnedDigest(img *runtimes.Image) error {
+	if img.Digest == "" {
+		return nil
+	}
+
+	inspect, err := images.GetImage(*m.ctx, img.Reference(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image: %w", err)
+	}
+	if inspect.Digest.String() == img.Digest {
+		return nil
+	}
+	for _, repoDigest := range inspect.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+img.Digest) {
+			return nil
+		}
+	}
+	return &ErrImageDigestMismatch{Image: img.FullyQualifiedName, Expected: img.Digest, Actual: inspect.Digest.String()}
+}
+
+// Check the local image is signed with the manager's signature key, sigstore style
+// The digest of the image that was pulled is verified, not whatever the tag points to now
+// Signatures are fetched from the registry as sigstore attachments
+// Successful verifications are cached for the lifetime of the manager
+func (m *Manager) verifySignature(ref string) error {
+	if m.signatureKey == "" {
+		return nil
+	}
+
+	inspect, err := images.GetImage(*m.ctx, ref, nil)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image: %w", err)
+	}
+	candidates, err := digestReferences(ref, inspect.Digest.String(), inspect.RepoDigests)
+	if err != nil {
+		return &ErrSignatureInvalid{Image: ref, Err: err}
+	}
+	for _, candidate := range candidates {
+		if _, ok := m.verified.Load(candidate); ok {
+			return nil
+		}
+	}
+
+	requirement, err := signature.NewPRSigstoreSignedKeyPath(m.signatureKey, signature.NewPRMMatchRepoDigestOrExact())
+	if err != nil {
+		return fmt.Errorf("invalid signature key: %w", err)
+	}
+	policy, err := signature.NewPolicyContext(&signature.Policy{Default: signature.PolicyRequirements{requirement}})
+	if err != nil {
+		return err
+	}
+	defer policy.Destroy()
+
+	// sigstore attachments are only looked up when enabled in registries.d
+	registriesDir, err := os.MkdirTemp("", "studentbox-registries.d-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(registriesDir)
+	config := "default-docker:\n  use-sigstore-attachments: true\n"
+	if err := os.WriteFile(filepath.Join(registriesDir, "studentbox.yaml"), []byte(config), 0644); err != nil {
+		return err
+	}
+	sys := &types.SystemContext{RegistriesDirPath: registriesDir, AuthFilePath: m.authFile}
+
+	// a multi-arch image may be signed by its index digest or its manifest digest
+	errs := make([]error, 0, len(candidates))
+	for _, candidate := range candidates {
+		err := verifyDigestReference(policy, sys, candidate)
+		if err == nil {
+			m.log.Info("verified signature", "image", ref, "digest", candidate)
+			m.verified.Store(candidate, true)
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", candidate, err))
+	}
+	return &ErrSignatureInvalid{Image: ref, Err: errors.Join(errs...)}
+}
+
+// Check the signatures of a reference by digest against the policy
+func verifyDigestReference(policy *signature.PolicyContext, sys *types.SystemContext, digestRef string) error {
+	ref, err := docker.ParseReference("//" + digestRef)
+	if err != nil {
+		return fmt.Errorf("invalid image reference: %w", err)
+	}
+	ctx := context.Background()
+	src, err := ref.NewImageSource(ctx, sys)
+	if err != nil {
+		return fmt.Errorf("failed to fetch image for verification: %w", err)
+	}
+	defer src.Close()
+
+	allowed, err := policy.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errors.New("rejected by the signature policy")
+	}
+	return nil
+}
+
+// References by digest of a local image in the repository of ref (e.g. ghcr.io/a/b@sha256:...)
+// digest and repoDigests come from the image's inspect data
+func digestReferences(ref, digest string, repoDigests []string) ([]string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image reference: %w", err)
+	}
+	repository := named.Name()
+
+	candidates := make([]string, 0, len(repoDigests)+1)
+	seen := make(map[string]bool)
+	add := func(candidate string) {
+		if !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	for _, repoDigest := range repoDigests {
+		if strings.HasPrefix(repoDigest, repository+"@") {
+			add(repoDigest)
+		}
+	}
+	if digest != "" {
+		add(repository + "@" + digest)
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("the local image has no digest, it wasn't pulled from a registry")
+	}
+	return candidates, nil
+}
@@ -0,0 +1,52 @@
+package containers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDigestReferences(t *testing.T) {
+	tests := []struct {
+		name        string
+		ref         string
+		digest      string
+		repoDigests []string
+		expected    []string
+	}{
+		{
+			name:        "tag moved, local digest wins",
+			ref:         "ghcr.io/a/b:latest",
+			digest:      "sha256:local",
+			repoDigests: []string{"ghcr.io/a/b@sha256:index", "mirror.example.edu/b@sha256:index"},
+			expected:    []string{"ghcr.io/a/b@sha256:index", "ghcr.io/a/b@sha256:local"},
+		},
+		{
+			name:     "docker hub",
+			ref:      "mariadb:10.9",
+			digest:   "sha256:local",
+			expected: []string{"docker.io/library/mariadb@sha256:local"},
+		},
+		{
+			name:     "built locally",
+			ref:      "ghcr.io/a/b:local",
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := digestReferences(tt.ref, tt.digest, tt.repoDigests)
+			if tt.expected == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
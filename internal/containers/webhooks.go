@@ -0,0 +1,71 @@
+package containers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sinux-l5d/studentbox/internal/webhook"
+)
+
+// Name of the webhooks dead-letter file in the data directory, when ManagerOptions.WebhookDeadLetter is empty
+const webhookDeadLetterFile = ".webhooks-dead.jsonl"
+
+// Webhook events sent by the manager, on top of the Event types forwarded by ForwardEvents
+// There is no quota exceeded event: studentbox enforces no quota of its own, and a container
+// killed for exceeding its memory limit is reported as EventContainerDied with exit code 137
+const (
+	WebhookSpawnSucceeded = "spawn-succeeded"
+	WebhookSpawnFailed    = "spawn-failed"
+	WebhookExpiryWarning  = "expiry-warning"
+	WebhookExpired        = "project-expired"
+)
+
+const (
+	webhookRetries = 3
+	webhookBackoff = time.Second
+	// Longest an operation waits for its webhooks, retries included
+	// Payloads not delivered by then go to the dead-letter file
+	webhookTimeout = 5 * time.Second
+)
+
+// Send a webhook, failures are logged as the payload is in the dead-letter file
+func (m *Manager) notify(event, user, project string, data map[string]any) {
+	if m.webhooks == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	payload := webhook.Payload{Event: event, User: user, Project: project, Data: data}
+	if err := m.webhooks.Send(ctx, payload); err != nil {
+		m.log.Error("failed to send webhook", "event", event, "user", user, "project", project, "error", err)
+	}
+}
+
+// Send lifecycle events (see Events) to webhooks until ctx is done
+// Meant for long-running processes, as events aren't stored
+func (m *Manager) ForwardEvents(ctx context.Context) error {
+	if m.webhooks == nil {
+		return nil
+	}
+	events, errs := m.Events(ctx, EventFilter{Follow: true})
+	for event := range events {
+		data := map[string]any{"pod_id": event.PodID}
+		if event.Container != "" {
+			data["container"] = event.Container
+			data["container_id"] = event.ContainerID
+		}
+		if event.ExitCode != nil {
+			data["exit_code"] = *event.ExitCode
+		}
+		if event.Health != "" {
+			data["health"] = event.Health
+		}
+		payload := webhook.Payload{Event: event.Type, Time: event.Time, User: event.User, Project: event.Project, Data: data}
+		sendCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		if err := m.webhooks.Send(sendCtx, payload); err != nil {
+			m.log.Error("failed to send webhook", "event", event.Type, "user", event.User, "project", event.Project, "error", err)
+		}
+		cancel()
+	}
+	return <-errs
+}
@@ -1,16 +1,28 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sinux-l5d/studentbox/internal/runtimes"
 )
 
+// Registry and namespace of runtime images, runtimes.DefaultImagePrefix if STUDENTBOX_IMAGE_PREFIX isn't set
+func imagePrefix() string {
+	prefix := os.Getenv("STUDENTBOX_IMAGE_PREFIX")
+	if prefix == "" {
+		return runtimes.DefaultImagePrefix
+	}
+	return prefix
+}
+
 func getImageConfigFromFile(path string) (*runtimes.Image, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -28,13 +40,15 @@ func getImageConfigFromFile(path string) (*runtimes.Image, error) {
 	shortName := strings.TrimSuffix(filepath.Base(path), ".containerfile")
 	image := &runtimes.Image{
 		ShortName:          shortName,
-		FullyQualifiedName: "ghcr.io/sinux-l5d/studentbox/runtime/" + runtime + "." + shortName,
+		FullyQualifiedName: runtimes.ImageName(imagePrefix(), runtime, shortName),
 		Mounts:             make(map[string]string),
 		EnvVars:             make([]*runtimes.EnvVar, 0),
 	}
 	// extract labels from content
 	labels, err := extractLabelsFromDockerfile(string(contentBytes))
 	die(err)
+	// the healthcheck label is set, possibly to NONE
+	healthcheckLabel := false
 	for name, value := range labels {
 		switch name {
 		case "studentbox.config.mounts":
@@ -52,9 +66,39 @@ func getImageConfigFromFile(path string) (*runtimes.Image, error) {
 			envvars, err := parseEnvConfig(value, defaultsValues)
 			die(err)
 			image.EnvVars = envvars
+		case "studentbox.config.depends_on":
+			dependencies, err := parseDependsOn(value)
+			die(err)
+			image.DependsOn = dependencies
+		case "studentbox.config.healthcheck":
+			healthcheck, err := parseHealthcheck(value)
+			die(err)
+			image.Healthcheck = healthcheck
+			healthcheckLabel = true
+		case "studentbox.config.restart":
+			policy, err := runtimes.ParseRestartPolicy(value)
+			die(err)
+			image.RestartPolicy = policy
+		case "studentbox.config.shared":
+			shared, err := runtimes.ParseShared(value)
+			die(err)
+			image.Shared = shared
+		case "studentbox.config.provision":
+			image.Provision = strings.Fields(value)
+		case "studentbox.config.dump":
+			image.Dump = strings.Fields(value)
+		case "studentbox.config.deprovision":
+			image.Deprovision = strings.Fields(value)
 		}
 
 	}
+
+	// label takes precedence over instruction, even to disable it
+	if !healthcheckLabel {
+		healthcheck, err := extractHealthcheckFromDockerfile(string(contentBytes))
+		die(err)
+		image.Healthcheck = healthcheck
+	}
 	return image, nil
 }
 
@@ -170,3 +214,141 @@ func parseModifiers(modifiers string) ([]runtimes.EnvModifierParams, error) {
 	}
 	return envModifiers, nil
 }
+
+// Return the healthcheck of the HEALTHCHECK instruction, nil if not found or disabled
+// Only the last instruction is used, like podman does
+func extractHealthcheckFromDockerfile(dockerfile string) (*runtimes.Healthcheck, error) {
+	var healthcheck *runtimes.Healthcheck
+
+	lines := strings.Split(dockerfile, "\n")
+	for _, line := range lines {
+		args, found := strings.CutPrefix(strings.TrimSpace(line), "HEALTHCHECK ")
+		if !found {
+			continue
+		}
+		h, err := parseHealthcheck(args)
+		if err != nil {
+			return nil, err
+		}
+		healthcheck = h
+	}
+	return healthcheck, nil
+}
+
+// Parse HEALTHCHECK arguments, also used by studentbox.config.healthcheck
+// e.g. : "--interval=5s --retries=3 CMD mariadb-admin ping", "CMD [\"curl\", \"-f\", \"http://localhost\"]", "NONE"
+func parseHealthcheck(args string) (*runtimes.Healthcheck, error) {
+	healthcheck := &runtimes.Healthcheck{}
+
+	rest := strings.TrimSpace(args)
+	for strings.HasPrefix(rest, "--") {
+		option, remaining, _ := strings.Cut(rest, " ")
+		rest = strings.TrimSpace(remaining)
+
+		name, value, found := strings.Cut(strings.TrimPrefix(option, "--"), "=")
+		if !found {
+			return nil, fmt.Errorf("invalid healthcheck option %s", option)
+		}
+
+		var err error
+		switch name {
+		case "interval":
+			healthcheck.Interval, err = time.ParseDuration(value)
+		case "timeout":
+			healthcheck.Timeout, err = time.ParseDuration(value)
+		case "start-period":
+			healthcheck.StartPeriod, err = time.ParseDuration(value)
+		case "retries":
+			healthcheck.Retries, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown healthcheck option %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rest == "NONE" {
+		return nil, nil
+	}
+
+	command, found := strings.CutPrefix(rest, "CMD ")
+	if !found || strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("invalid healthcheck %s", args)
+	}
+	command = strings.TrimSpace(command)
+
+	// exec form
+	if strings.HasPrefix(command, "[") {
+		var execArgs []string
+		if err := json.Unmarshal([]byte(command), &execArgs); err != nil {
+			return nil, fmt.Errorf("invalid healthcheck command %s: %w", command, err)
+		}
+		healthcheck.Test = append([]string{"CMD"}, execArgs...)
+		return healthcheck, nil
+	}
+
+	healthcheck.Test = []string{"CMD-SHELL", command}
+	return healthcheck, nil
+}
+
+// Parse a studentbox.config.depends_on string into a slice of Dependency
+// e.g. : "php,mysql:healthy"
+func parseDependsOn(dependsOn string) ([]runtimes.Dependency, error) {
+	rawDeps := strings.Split(dependsOn, ",")
+	dependencies := make([]runtimes.Dependency, len(rawDeps))
+
+	for i, rawDep := range rawDeps {
+		name, condition, found := strings.Cut(strings.TrimSpace(rawDep), ":")
+		if name == "" {
+			return nil, fmt.Errorf("invalid dependency %s", rawDep)
+		}
+		if !found {
+			condition = runtimes.ConditionStarted
+		}
+		if condition != runtimes.ConditionStarted && condition != runtimes.ConditionHealthy {
+			return nil, fmt.Errorf("invalid dependency condition %s", condition)
+		}
+		dependencies[i] = runtimes.Dependency{Image: name, Condition: condition}
+	}
+	return dependencies, nil
+}
+
+// Tag and digest an image is pinned to
+type imagePin struct {
+	Tag    string
+	Digest string
+}
+
+// Read a runtime's images.lock, return an empty map if it doesn't exist
+// Each line is "<short name> <tag>", "<short name> <tag>@<digest>" or "<short name> @<digest>"
+// e.g. : "mysql 2023.1@sha256:4f2d..."
+func readImagesLock(path string) (map[string]imagePin, error) {
+	pins := make(map[string]imagePin)
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<short name> <tag>[@<digest>]\"", path, i+1)
+		}
+		tag, digest, _ := strings.Cut(fields[1], "@")
+		if digest != "" && !strings.Contains(digest, ":") {
+			return nil, fmt.Errorf("%s:%d: invalid digest %s", path, i+1, digest)
+		}
+		pins[fields[0]] = imagePin{Tag: tag, Digest: digest}
+	}
+	return pins, nil
+}
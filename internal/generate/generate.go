@@ -5,6 +5,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -42,6 +43,8 @@ var OfficialRuntimes = map[string]Runtime{
 			"{{ .ShortName }}": {
 				FullyQualifiedName: "{{ .FullyQualifiedName }}",
 				ShortName:          "{{ .ShortName }}",
+				Tag:                "{{ .Tag }}",
+				Digest:             "{{ .Digest }}",
 				Mounts: map[string]string{
 					{{- range $key, $value := .Mounts }}
 					"{{ $key }}": "{{ $value }}",
@@ -67,6 +70,51 @@ var OfficialRuntimes = map[string]Runtime{
 					},
 					{{- end }}
 				},
+				DependsOn: []Dependency{
+					{{- range .DependsOn }}
+					{Image: "{{ .Image }}", Condition: "{{ .Condition }}"},
+					{{- end }}
+				},
+				{{- with .Healthcheck }}
+				Healthcheck: &Healthcheck{
+					Test: []string{
+						{{- range .Test }}
+						{{ printf "%q" . }},
+						{{- end }}
+					},
+					Interval:    {{ .Interval.Nanoseconds }},
+					Timeout:     {{ .Timeout.Nanoseconds }},
+					StartPeriod: {{ .StartPeriod.Nanoseconds }},
+					Retries:     {{ .Retries }},
+				},
+				{{- end }}
+				{{- if .RestartPolicy.Name }}
+				RestartPolicy: RestartPolicy{Name: "{{ .RestartPolicy.Name }}", MaxRetries: {{ .RestartPolicy.MaxRetries }}},
+				{{- end }}
+				{{- if .Shared }}
+				Shared: true,
+				{{- end }}
+				{{- with .Provision }}
+				Provision: []string{
+					{{- range . }}
+					{{ printf "%q" . }},
+					{{- end }}
+				},
+				{{- end }}
+				{{- with .Dump }}
+				Dump: []string{
+					{{- range . }}
+					{{ printf "%q" . }},
+					{{- end }}
+				},
+				{{- end }}
+				{{- with .Deprovision }}
+				Deprovision: []string{
+					{{- range . }}
+					{{ printf "%q" . }},
+					{{- end }}
+				},
+				{{- end }}
 			},
 			{{- end }}
 		{{- end }}
@@ -106,6 +154,22 @@ func generateRuntimes() {
 				forTemplate[runtimeName].Images[imgConfig.ShortName] = *imgConfig
 			}
 		}
+
+		// pin images to the tags and digests of the lock file, if any
+		pins, err := readImagesLock("../../runtimes/" + runtimeName + "/images.lock")
+		die(err)
+		for shortName, pin := range pins {
+			image, exists := forTemplate[runtimeName].Images[shortName]
+			if !exists {
+				die(fmt.Errorf("images.lock of runtime %s pins unknown image %s", runtimeName, shortName))
+			}
+			image.Tag, image.Digest = pin.Tag, pin.Digest
+			forTemplate[runtimeName].Images[shortName] = image
+		}
+
+		// fail early on unknown dependencies or cycles
+		_, err = forTemplate[runtimeName].StartOrder()
+		die(err)
 	}
 
 	// print toTemplate as json
@@ -0,0 +1,124 @@
+// Minimal Prometheus text exposition format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric types
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+type Labels map[string]string
+
+// Label pairs sorted by name, in exposition format (e.g. {a="1",b="2"})
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(l[name])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Copy of the labels with an additional label
+func (l Labels) With(name, value string) Labels {
+	labels := make(Labels, len(l)+1)
+	for k, v := range l {
+		labels[k] = v
+	}
+	labels[name] = value
+	return labels
+}
+
+type Sample struct {
+	// Appended to the family name, for histograms (e.g. _bucket)
+	Suffix string
+	Labels Labels
+	Value  float64
+}
+
+// A metric and its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+func NewFamily(name, help, typ string) *Family {
+	return &Family{Name: name, Help: help, Type: typ}
+}
+
+func (f *Family) Add(value float64, labels Labels) {
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// Add the samples of a histogram: cumulative buckets, sum and count
+// counts[i] is the number of observations <= bounds[i], not cumulative
+func (f *Family) AddHistogram(bounds []float64, counts []uint64, sum float64, labels Labels) {
+	var cumulative uint64
+	for i, bound := range bounds {
+		cumulative += counts[i]
+		f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: labels.With("le", formatValue(bound)), Value: float64(cumulative)})
+	}
+	if len(counts) > len(bounds) {
+		cumulative += counts[len(bounds)]
+	}
+	f.Samples = append(f.Samples,
+		Sample{Suffix: "_bucket", Labels: labels.With("le", "+Inf"), Value: float64(cumulative)},
+		Sample{Suffix: "_sum", Labels: labels, Value: sum},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(cumulative)},
+	)
+}
+
+// Write families in the text exposition format
+func Write(w io.Writer, families []*Family) error {
+	for _, f := range families {
+		if len(f.Samples) == 0 {
+			continue
+		}
+		if f.Help != "" {
+			help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.Help)
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", f.Name, help); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s%s %s\n", f.Name, s.Suffix, s.Labels, formatValue(s.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,72 @@
+package metrics_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/metrics"
+)
+
+func TestWrite(t *testing.T) {
+	gauge := metrics.NewFamily("sb_memory_bytes", "Memory usage", metrics.Gauge)
+	gauge.Add(1024, metrics.Labels{"user": "alice", "project": "web"})
+	gauge.Add(0.5, metrics.Labels{"user": `quo"te`})
+	empty := metrics.NewFamily("sb_empty", "Skipped", metrics.Counter)
+
+	var out strings.Builder
+	if err := metrics.Write(&out, []*metrics.Family{gauge, empty}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP sb_memory_bytes Memory usage
+# TYPE sb_memory_bytes gauge
+sb_memory_bytes{project="web",user="alice"} 1024
+sb_memory_bytes{user="quo\"te"} 0.5
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := metrics.NewStore(filepath.Join(t.TempDir(), "metrics.json"))
+	store.Describe("sb_spawns_total", "Spawns")
+	labels := metrics.Labels{"runtime": "lamp"}
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"inc", func() error { return store.Inc("sb_spawns_total", labels, 1) }},
+		{"inc again", func() error { return store.Inc("sb_spawns_total", labels, 2) }},
+		{"observe low", func() error { return store.Observe("sb_spawn_seconds", labels, 0.5, []float64{1, 10}) }},
+		{"observe inf", func() error { return store.Observe("sb_spawn_seconds", labels, 42, []float64{1, 10}) }},
+	}
+	for _, tt := range tests {
+		if err := tt.run(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+	}
+
+	families, err := store.Families()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := metrics.Write(&out, families); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# TYPE sb_spawn_seconds histogram
+sb_spawn_seconds_bucket{le="1",runtime="lamp"} 1
+sb_spawn_seconds_bucket{le="10",runtime="lamp"} 1
+sb_spawn_seconds_bucket{le="+Inf",runtime="lamp"} 2
+sb_spawn_seconds_sum{runtime="lamp"} 42.5
+sb_spawn_seconds_count{runtime="lamp"} 2
+# HELP sb_spawns_total Spawns
+# TYPE sb_spawns_total counter
+sb_spawns_total{runtime="lamp"} 3
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
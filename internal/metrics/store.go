@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Default histogram buckets for durations in seconds
+var DurationBuckets = []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300}
+
+type counter struct {
+	Labels Labels  `json:"labels"`
+	Value  float64 `json:"value"`
+}
+
+type histogram struct {
+	Labels Labels    `json:"labels"`
+	Bounds []float64 `json:"bounds"`
+	// One more count than bounds, for +Inf
+	Counts []uint64 `json:"counts"`
+	Sum    float64  `json:"sum"`
+}
+
+type storeContent struct {
+	// Key is the metric name, then the labels string
+	Counters   map[string]map[string]*counter   `json:"counters"`
+	Histograms map[string]map[string]*histogram `json:"histograms"`
+}
+
+// Counters and histograms persisted in a JSON file, so they survive the process
+// and can be updated by several processes (each CLI invocation) and read by an exporter
+type Store struct {
+	path string
+	help map[string]string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path, help: make(map[string]string)}
+}
+
+// Set the help text of a metric, used by Families
+func (s *Store) Describe(name, help string) {
+	s.help[name] = help
+}
+
+// Add delta to a counter
+func (s *Store) Inc(name string, labels Labels, delta float64) error {
+	return s.update(func(c *storeContent) {
+		key := labels.String()
+		if c.Counters[name] == nil {
+			c.Counters[name] = make(map[string]*counter)
+		}
+		if c.Counters[name][key] == nil {
+			c.Counters[name][key] = &counter{Labels: labels}
+		}
+		c.Counters[name][key].Value += delta
+	})
+}
+
+// Record a value in a histogram
+// bounds must be sorted and stay the same for a given metric
+func (s *Store) Observe(name string, labels Labels, value float64, bounds []float64) error {
+	return s.update(func(c *storeContent) {
+		key := labels.String()
+		if c.Histograms[name] == nil {
+			c.Histograms[name] = make(map[string]*histogram)
+		}
+		h := c.Histograms[name][key]
+		if h == nil || len(h.Bounds) != len(bounds) {
+			h = &histogram{Labels: labels, Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+			c.Histograms[name][key] = h
+		}
+		h.Counts[sort.SearchFloat64s(bounds, value)]++
+		h.Sum += value
+	})
+}
+
+// Read all metrics of the store, sorted by name and labels
+func (s *Store) Families() ([]*Family, error) {
+	content := &storeContent{}
+	err := s.locked(syscall.LOCK_SH, func() error {
+		var err error
+		content, err = s.read()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	families := make([]*Family, 0, len(content.Counters)+len(content.Histograms))
+	for name, series := range content.Counters {
+		f := NewFamily(name, s.help[name], Counter)
+		for _, key := range sortedKeys(series) {
+			f.Add(series[key].Value, series[key].Labels)
+		}
+		families = append(families, f)
+	}
+	for name, series := range content.Histograms {
+		f := NewFamily(name, s.help[name], Histogram)
+		for _, key := range sortedKeys(series) {
+			h := series[key]
+			f.AddHistogram(h.Bounds, h.Counts, h.Sum, h.Labels)
+		}
+		families = append(families, f)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+	return families, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Store) update(change func(*storeContent)) error {
+	return s.locked(syscall.LOCK_EX, func() error {
+		content, err := s.read()
+		if err != nil {
+			return err
+		}
+		change(content)
+
+		encoded, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		tmp := s.path + ".tmp"
+		if err := os.WriteFile(tmp, encoded, 0644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, s.path)
+	})
+}
+
+func (s *Store) read() (*storeContent, error) {
+	content := &storeContent{}
+	encoded, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(encoded))) > 0 {
+		if err := json.Unmarshal(encoded, content); err != nil {
+			return nil, err
+		}
+	}
+	if content.Counters == nil {
+		content.Counters = make(map[string]map[string]*counter)
+	}
+	if content.Histograms == nil {
+		content.Histograms = make(map[string]map[string]*histogram)
+	}
+	return content, nil
+}
+
+// Run f holding a flock on a lock file next to the store
+func (s *Store) locked(how int, f func() error) error {
+	lock, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), how); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return f()
+}
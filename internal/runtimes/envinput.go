@@ -0,0 +1,175 @@
+package runtimes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Environment variables given by the user when spawning a runtime
+type InputEnvVars struct {
+	// Env vars passed to all images
+	Global map[string]string
+	// Env vars passed to a single image, key is the image short name
+	PerImage map[string]map[string]string
+}
+
+type ErrInvalidEnvVar struct {
+	Value string
+	// Line number in env file, 0 if not from a file
+	Line int
+}
+
+func (e *ErrInvalidEnvVar) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("invalid environment variable %s (line %d)", e.Value, e.Line)
+	}
+	return "invalid environment variable " + e.Value
+}
+
+func NewInputEnvVars() *InputEnvVars {
+	return &InputEnvVars{
+		Global:   make(map[string]string),
+		PerImage: make(map[string]map[string]string),
+	}
+}
+
+// Set a single env var, for all images if image is empty
+func (in *InputEnvVars) Set(image, name, value string) {
+	if image == "" {
+		in.Global[name] = value
+		return
+	}
+	if in.PerImage[image] == nil {
+		in.PerImage[image] = make(map[string]string)
+	}
+	in.PerImage[image][name] = value
+}
+
+// Check if an env var was given for the image, either globally or specifically
+func (in *InputEnvVars) Has(image, name string) bool {
+	if _, exists := in.Global[name]; exists {
+		return true
+	}
+	_, exists := in.PerImage[image][name]
+	return exists
+}
+
+// Parse and set an assignment of the form [image:]NAME=value
+// e.g. : "FOO=bar", "mysql:MARIADB_PASSWORD=secret"
+func (in *InputEnvVars) Parse(assignment string) error {
+	image, name, value, err := parseAssignment(assignment)
+	if err != nil {
+		return err
+	}
+	in.Set(image, name, value)
+	return nil
+}
+
+// Parse and set all assignments of a dotenv file
+// Supports comments, "export" prefix, single and double quoted values
+// Keys can be scoped to an image like with Parse
+func (in *InputEnvVars) ParseFile(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, rawValue, found := strings.Cut(line, "=")
+		if !found {
+			return &ErrInvalidEnvVar{Value: line, Line: lineNumber}
+		}
+		value, err := unquoteEnvValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return &ErrInvalidEnvVar{Value: line, Line: lineNumber}
+		}
+
+		image, name, value, err := parseAssignment(strings.TrimSpace(key) + "=" + value)
+		if err != nil {
+			return &ErrInvalidEnvVar{Value: line, Line: lineNumber}
+		}
+		in.Set(image, name, value)
+	}
+	return scanner.Err()
+}
+
+// Split an assignment of the form [image:]NAME=value
+// The value may be empty, e.g. to blank a default value
+func parseAssignment(assignment string) (string, string, string, error) {
+	key, value, found := strings.Cut(assignment, "=")
+	if !found || key == "" {
+		return "", "", "", &ErrInvalidEnvVar{Value: assignment}
+	}
+
+	image, name, scoped := strings.Cut(key, ":")
+	if !scoped {
+		return "", key, value, nil
+	}
+	if image == "" || name == "" {
+		return "", "", "", &ErrInvalidEnvVar{Value: assignment}
+	}
+	return image, name, value, nil
+}
+
+// Remove quotes and trailing comment of a dotenv value
+func unquoteEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		if !isComment(raw[end+2:]) {
+			return "", fmt.Errorf("unexpected content after quote")
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; c {
+			case '\\':
+				i++
+				if i == len(raw) {
+					return "", fmt.Errorf("unterminated quote")
+				}
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(raw[i])
+				}
+			case '"':
+				if !isComment(raw[i+1:]) {
+					return "", fmt.Errorf("unexpected content after quote")
+				}
+				return b.String(), nil
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated quote")
+	}
+
+	// unquoted, inline comments must be preceded by a space
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+func isComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
@@ -0,0 +1,101 @@
+package runtimes_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+func TestInputEnvVarsParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		image       string
+		key         string
+		expected    string
+		expectError bool
+	}{
+		{name: "Global", input: "FOO=bar", key: "FOO", expected: "bar"},
+		{name: "Scoped", input: "mysql:FOO=bar", image: "mysql", key: "FOO", expected: "bar"},
+		{name: "Value with equal", input: "FOO=a=b", key: "FOO", expected: "a=b"},
+		{name: "No equal", input: "FOO", expectError: true},
+		{name: "Empty value", input: "FOO=", key: "FOO", expected: ""},
+		{name: "Empty scoped value", input: "mysql:FOO=", image: "mysql", key: "FOO", expected: ""},
+		{name: "Empty key", input: "=bar", expectError: true},
+		{name: "Empty image", input: ":FOO=bar", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envvar := runtimes.NewInputEnvVars()
+			err := envvar.Parse(test.input)
+			if test.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, but didn't get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Didn't expect an error, but got one: %v", err)
+			}
+
+			var got string
+			if test.image == "" {
+				got = envvar.Global[test.key]
+			} else {
+				got = envvar.PerImage[test.image][test.key]
+			}
+			if got != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestInputEnvVarsParseFile(t *testing.T) {
+	file := `
+# comment
+FOO=bar
+export EXPORTED=yes
+SPACED = value # inline comment
+DOUBLE="hello \"world\"\n" # comment
+SINGLE='no \n escape'
+HASH=a#b
+EMPTY=
+mysql:MARIADB_PASSWORD=secret
+`
+	envvar := runtimes.NewInputEnvVars()
+	if err := envvar.ParseFile(strings.NewReader(file)); err != nil {
+		t.Fatalf("Didn't expect an error, but got one: %v", err)
+	}
+
+	expected := map[string]string{
+		"FOO":      "bar",
+		"EXPORTED": "yes",
+		"SPACED":   "value",
+		"DOUBLE":   "hello \"world\"\n",
+		"SINGLE":   `no \n escape`,
+		"HASH":     "a#b",
+	}
+	for k, v := range expected {
+		if envvar.Global[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, envvar.Global[k])
+		}
+	}
+	if value, set := envvar.Global["EMPTY"]; !set || value != "" {
+		t.Errorf("Expected EMPTY to be set to an empty value, got %q (set: %t)", value, set)
+	}
+	if envvar.PerImage["mysql"]["MARIADB_PASSWORD"] != "secret" {
+		t.Errorf("Expected scoped env var to be set, got %v", envvar.PerImage)
+	}
+	if !envvar.Has("mysql", "MARIADB_PASSWORD") || envvar.Has("php", "MARIADB_PASSWORD") {
+		t.Errorf("Has doesn't respect image scope")
+	}
+
+	for _, invalid := range []string{"FOO", `FOO="unterminated`, `FOO='a' b`} {
+		if err := runtimes.NewInputEnvVars().ParseFile(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q, but didn't get one", invalid)
+		}
+	}
+}
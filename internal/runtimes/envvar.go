@@ -39,6 +39,16 @@ var EnvModifiers = map[string]EnvModifier{
 	"failempty": &FailEmptyModifier{},
 }
 
+// Check if the env var uses the given modifier
+func (e EnvVar) HasModifier(name string) bool {
+	for _, modifier := range e.Modifiers {
+		if modifier.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Compute the value of an env var by applying modifiers
 func (e EnvVar) ApplyModifiers() (string, error) {
 	return e.ApplyModifiersWithInput(nil)
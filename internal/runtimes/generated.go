@@ -8,15 +8,22 @@ var OfficialRuntimes = map[string]Runtime{
 			"apache": {
 				FullyQualifiedName: "ghcr.io/sinux-l5d/studentbox/runtime/lamp.apache",
 				ShortName:          "apache",
+				Tag:                "",
+				Digest:             "",
 				Mounts: map[string]string{
 					"html": "/var/www/html",
 				},
 				EnvVars: []*EnvVar{
 				},
+				DependsOn: []Dependency{
+					{Image: "php", Condition: "started"},
+				},
 			},
 			"mysql": {
 				FullyQualifiedName: "ghcr.io/sinux-l5d/studentbox/runtime/lamp.mysql",
 				ShortName:          "mysql",
+				Tag:                "",
+				Digest:             "",
 				Mounts: map[string]string{
 					"db": "/var/lib/mysql",
 				},
@@ -68,15 +75,43 @@ var OfficialRuntimes = map[string]Runtime{
 						},
 					},
 				},
+				DependsOn: []Dependency{
+				},
+				Healthcheck: &Healthcheck{
+					Test: []string{
+						"CMD-SHELL",
+						"mariadb-admin ping -h 127.0.0.1 --silent",
+					},
+					Interval:    5000000000,
+					Timeout:     3000000000,
+					StartPeriod: 10000000000,
+					Retries:     10,
+				},
+				RestartPolicy: RestartPolicy{Name: "on-failure", MaxRetries: 5},
+				Shared: true,
+				Provision: []string{
+					"studentbox-provision",
+				},
+				Dump: []string{
+					"studentbox-dump",
+				},
+				Deprovision: []string{
+					"studentbox-deprovision",
+				},
 			},
 			"php": {
 				FullyQualifiedName: "ghcr.io/sinux-l5d/studentbox/runtime/lamp.php",
 				ShortName:          "php",
+				Tag:                "",
+				Digest:             "",
 				Mounts: map[string]string{
 					"html": "/var/www/html",
 				},
 				EnvVars: []*EnvVar{
 				},
+				DependsOn: []Dependency{
+					{Image: "mysql", Condition: "started"},
+				},
 			},
 		},
 	},
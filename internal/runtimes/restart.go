@@ -0,0 +1,73 @@
+package runtimes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Never restart, podman's default
+	RestartNo = "no"
+	// Restart when the container exits with a non-zero code, up to MaxRetries times if set
+	RestartOnFailure = "on-failure"
+	// Always restart, whatever the exit code
+	RestartAlways = "always"
+)
+
+// When podman restarts an exited container
+// The zero value leaves podman's default
+type RestartPolicy struct {
+	Name string
+	// Maximum restarts for RestartOnFailure, unlimited if 0
+	MaxRetries uint
+}
+
+type ErrInvalidRestartPolicy struct {
+	Value string
+}
+
+func (e *ErrInvalidRestartPolicy) Error() string {
+	return fmt.Sprintf("invalid restart policy \"%s\", expected no, on-failure[:N] or always", e.Value)
+}
+
+// Parse a restart policy as in podman run --restart: no, on-failure[:N] or always
+func ParseRestartPolicy(value string) (RestartPolicy, error) {
+	name, retries, hasRetries := strings.Cut(value, ":")
+	policy := RestartPolicy{Name: name}
+	switch name {
+	case RestartNo, RestartAlways:
+		if hasRetries {
+			return RestartPolicy{}, &ErrInvalidRestartPolicy{Value: value}
+		}
+	case RestartOnFailure:
+		if hasRetries {
+			n, err := strconv.ParseUint(retries, 10, 32)
+			if err != nil || n == 0 {
+				return RestartPolicy{}, &ErrInvalidRestartPolicy{Value: value}
+			}
+			policy.MaxRetries = uint(n)
+		}
+	default:
+		return RestartPolicy{}, &ErrInvalidRestartPolicy{Value: value}
+	}
+	return policy, nil
+}
+
+// Policy in the format of ParseRestartPolicy, empty for the zero value
+func (p RestartPolicy) String() string {
+	if p.Name == RestartOnFailure && p.MaxRetries > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaxRetries)
+	}
+	return p.Name
+}
+
+// Copy of the runtime with the same restart policy for all images
+func (r Runtime) WithRestartPolicy(policy RestartPolicy) Runtime {
+	restarted := Runtime{Name: r.Name, Images: make(map[string]Image, len(r.Images))}
+	for name, image := range r.Images {
+		image.RestartPolicy = policy
+		restarted.Images[name] = image
+	}
+	return restarted
+}
@@ -1,19 +1,85 @@
 package runtimes
 
 import (
+	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/containers/image/v5/manifest"
 	"github.com/containers/podman/v4/pkg/specgen"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
 // Define config for an image
 type Image struct {
+	// Image name without tag nor digest
 	FullyQualifiedName string
 	ShortName          string
+	// Pinned tag, latest if empty
+	Tag string
+	// Pinned digest (e.g. sha256:...), enforced after pull if not empty
+	Digest string
 	// key is a single directory name, value is the full path container side
 	Mounts map[string]string
 	EnvVars []*EnvVar
+	// nil if the image has no healthcheck
+	Healthcheck *Healthcheck
+	// Images of the same runtime that must be started before this one
+	DependsOn []Dependency
+	// Podman's default (no restart) if zero
+	RestartPolicy RestartPolicy
+	// Run once per user and shared by all their projects, instead of once per project
+	Shared bool
+	// Command run in the shared container to provision a project (e.g. create its database)
+	// It prints the project's env vars as KEY=VALUE lines
+	Provision []string
+	// Command run in the shared container to dump a project's data (e.g. its database) for snapshots
+	// It prints the dump
+	Dump []string
+	// Command run in the shared container when a project is destroyed (e.g. drop its database)
+	Deprovision []string
+}
+
+const (
+	// Dependency is satisfied once its container is started
+	ConditionStarted = "started"
+	// Dependency is satisfied once its container is healthy, requires a healthcheck
+	ConditionHealthy = "healthy"
+)
+
+// Dependency of an image on another image of the same runtime
+type Dependency struct {
+	// Short name of the image depended on
+	Image string
+	// ConditionStarted or ConditionHealthy
+	Condition string
+}
+
+// Define how to check an image's container is healthy
+// Zero durations and retries mean podman's defaults
+type Healthcheck struct {
+	// Either {"CMD", args...} or {"CMD-SHELL", command}
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+// Prefix of runtime images names, unless another one is given at generate time
+const DefaultImagePrefix = "ghcr.io/sinux-l5d/studentbox/runtime/"
+
+// Tag of images built locally from the runtimes directory
+const LocalTag = "local"
+
+// Name of a runtime's image, e.g. "ghcr.io/sinux-l5d/studentbox/runtime/lamp.mysql"
+func ImageName(prefix, runtime, shortName string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + runtime + "." + shortName
 }
 
 // Define config for a runtime
@@ -39,8 +105,145 @@ func (r Runtime) MountNames() []string {
 	return keys
 }
 
+type ErrDependencyCycle struct {
+	Runtime string
+	Images  []string
+}
+
+func (e *ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("runtime \"%s\" has a dependency cycle between images: %s", e.Runtime, strings.Join(e.Images, ", "))
+}
+
+type ErrInvalidDependency struct {
+	Runtime    string
+	Image      string
+	Dependency Dependency
+}
+
+func (e *ErrInvalidDependency) Error() string {
+	return fmt.Sprintf("image \"%s\" of runtime \"%s\" has an invalid dependency on \"%s\" (condition \"%s\")", e.Image, e.Runtime, e.Dependency.Image, e.Dependency.Condition)
+}
+
+// Get images in the order they must be started, dependencies first
+// Images without dependencies between them are sorted by short name
+func (r Runtime) StartOrder() ([]Image, error) {
+	names := make([]string, 0, len(r.Images))
+	for name, image := range r.Images {
+		for _, dep := range image.DependsOn {
+			target, exists := r.Images[dep.Image]
+			if !exists || dep.Image == name {
+				return nil, &ErrInvalidDependency{Runtime: r.Name, Image: name, Dependency: dep}
+			}
+			switch dep.Condition {
+			case "", ConditionStarted:
+			case ConditionHealthy:
+				if target.Healthcheck == nil {
+					return nil, &ErrInvalidDependency{Runtime: r.Name, Image: name, Dependency: dep}
+				}
+			default:
+				return nil, &ErrInvalidDependency{Runtime: r.Name, Image: name, Dependency: dep}
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Kahn's algorithm
+	remaining := make(map[string]int, len(names))
+	dependents := make(map[string][]string, len(names))
+	for _, name := range names {
+		remaining[name] = len(r.Images[name].DependsOn)
+		for _, dep := range r.Images[name].DependsOn {
+			dependents[dep.Image] = append(dependents[dep.Image], name)
+		}
+	}
+
+	order := make([]Image, 0, len(names))
+	for len(order) < len(names) {
+		next := ""
+		for _, name := range names {
+			if count, ok := remaining[name]; ok && count == 0 {
+				next = name
+				break
+			}
+		}
+		if next == "" {
+			cycle := make([]string, 0, len(remaining))
+			for _, name := range names {
+				if _, ok := remaining[name]; ok {
+					cycle = append(cycle, name)
+				}
+			}
+			return nil, &ErrDependencyCycle{Runtime: r.Name, Images: cycle}
+		}
+
+		delete(remaining, next)
+		for _, dependent := range dependents[next] {
+			remaining[dependent]--
+		}
+		order = append(order, r.Images[next])
+	}
+	return order, nil
+}
+
+// Return a copy of the runtime with images names rewritten through a mirror map
+// Keys are registries or name prefixes (e.g. ghcr.io/sinux-l5d/studentbox/runtime), values their replacement
+// The longest matching prefix wins, matching whole path components only
+func (r Runtime) WithMirrors(mirrors map[string]string) Runtime {
+	if len(mirrors) == 0 {
+		return r
+	}
+
+	mirrored := Runtime{Name: r.Name, Images: make(map[string]Image, len(r.Images))}
+	for name, image := range r.Images {
+		image.FullyQualifiedName = mirrorName(image.FullyQualifiedName, mirrors)
+		mirrored.Images[name] = image
+	}
+	return mirrored
+}
+
+func mirrorName(name string, mirrors map[string]string) string {
+	bestFrom, bestTo := "", ""
+	for from, to := range mirrors {
+		from = strings.TrimSuffix(from, "/")
+		if name != from && !strings.HasPrefix(name, from+"/") {
+			continue
+		}
+		if len(from) > len(bestFrom) {
+			bestFrom, bestTo = from, strings.TrimSuffix(to, "/")
+		}
+	}
+	if bestFrom == "" {
+		return name
+	}
+	return bestTo + strings.TrimPrefix(name, bestFrom)
+}
+
+// Return a copy of the runtime using images built locally, tagged with LocalTag
+func (r Runtime) WithLocalImages() Runtime {
+	local := Runtime{Name: r.Name, Images: make(map[string]Image, len(r.Images))}
+	for name, image := range r.Images {
+		image.Tag, image.Digest = LocalTag, ""
+		local.Images[name] = image
+	}
+	return local
+}
+
+// Full reference of the image, including pinned tag and digest
+// e.g. : "ghcr.io/sinux-l5d/studentbox/runtime/lamp.mysql:v1@sha256:..."
+func (i Image) Reference() string {
+	ref := i.FullyQualifiedName
+	if i.Tag != "" {
+		ref += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		ref += "@" + i.Digest
+	}
+	return ref
+}
+
 func (i Image) ToContainerSpec(basePath string, inputEnvVar map[string]string) (*specgen.SpecGenerator, error) {
-	spec := specgen.NewSpecGenerator(i.FullyQualifiedName, false)
+	spec := specgen.NewSpecGenerator(i.Reference(), false)
 	spec.Terminal = true
 	spec.Env = make(map[string]string)
 
@@ -53,6 +256,24 @@ func (i Image) ToContainerSpec(basePath string, inputEnvVar map[string]string) (
 		})
 	}
 
+	if i.Healthcheck != nil {
+		spec.HealthConfig = &manifest.Schema2HealthConfig{
+			Test:        i.Healthcheck.Test,
+			Interval:    i.Healthcheck.Interval,
+			Timeout:     i.Healthcheck.Timeout,
+			StartPeriod: i.Healthcheck.StartPeriod,
+			Retries:     i.Healthcheck.Retries,
+		}
+	}
+
+	if i.RestartPolicy.Name != "" {
+		spec.RestartPolicy = i.RestartPolicy.Name
+		if i.RestartPolicy.MaxRetries > 0 {
+			retries := i.RestartPolicy.MaxRetries
+			spec.RestartRetries = &retries
+		}
+	}
+
 	// Firstly add all env vars from user input
 	for name, value := range inputEnvVar {
 		spec.Env[name] = value
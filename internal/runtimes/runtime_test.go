@@ -0,0 +1,224 @@
+package runtimes_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/runtimes"
+)
+
+func TestRuntimeStartOrder(t *testing.T) {
+	healthcheck := &runtimes.Healthcheck{Test: []string{"CMD", "true"}}
+	tests := []struct {
+		name        string
+		images      map[string]runtimes.Image
+		expected    string
+		expectCycle bool
+		expectError bool
+	}{
+		{
+			name: "No dependencies",
+			images: map[string]runtimes.Image{
+				"c": {ShortName: "c"},
+				"a": {ShortName: "a"},
+				"b": {ShortName: "b"},
+			},
+			expected: "a,b,c",
+		},
+		{
+			name: "Chain",
+			images: map[string]runtimes.Image{
+				"apache": {ShortName: "apache", DependsOn: []runtimes.Dependency{{Image: "php"}}},
+				"php":    {ShortName: "php", DependsOn: []runtimes.Dependency{{Image: "mysql", Condition: runtimes.ConditionHealthy}}},
+				"mysql":  {ShortName: "mysql", Healthcheck: healthcheck},
+			},
+			expected: "mysql,php,apache",
+		},
+		{
+			name: "Cycle",
+			images: map[string]runtimes.Image{
+				"a": {ShortName: "a", DependsOn: []runtimes.Dependency{{Image: "b"}}},
+				"b": {ShortName: "b", DependsOn: []runtimes.Dependency{{Image: "a"}}},
+				"c": {ShortName: "c"},
+			},
+			expectCycle: true,
+		},
+		{
+			name: "Unknown dependency",
+			images: map[string]runtimes.Image{
+				"a": {ShortName: "a", DependsOn: []runtimes.Dependency{{Image: "b"}}},
+			},
+			expectError: true,
+		},
+		{
+			name: "Healthy without healthcheck",
+			images: map[string]runtimes.Image{
+				"a": {ShortName: "a", DependsOn: []runtimes.Dependency{{Image: "b", Condition: runtimes.ConditionHealthy}}},
+				"b": {ShortName: "b"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runtime := runtimes.Runtime{Name: "test", Images: test.images}
+			order, err := runtime.StartOrder()
+
+			var cycleErr *runtimes.ErrDependencyCycle
+			if test.expectCycle {
+				if !errors.As(err, &cycleErr) {
+					t.Fatalf("Expected a cycle error, got %v", err)
+				}
+				if strings.Join(cycleErr.Images, ",") != "a,b" {
+					t.Errorf("Expected cycle between a,b, got %v", cycleErr.Images)
+				}
+				return
+			}
+			if test.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, but didn't get one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Didn't expect an error, but got one: %v", err)
+			}
+
+			names := make([]string, len(order))
+			for i, image := range order {
+				names[i] = image.ShortName
+			}
+			if strings.Join(names, ",") != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, strings.Join(names, ","))
+			}
+		})
+	}
+}
+
+func TestOfficialRuntimesStartOrder(t *testing.T) {
+	for name, runtime := range runtimes.OfficialRuntimes {
+		if _, err := runtime.StartOrder(); err != nil {
+			t.Errorf("Runtime %s: %v", name, err)
+		}
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	tests := map[string]runtimes.Image{
+		"ghcr.io/a/b":               {FullyQualifiedName: "ghcr.io/a/b"},
+		"ghcr.io/a/b:v1":            {FullyQualifiedName: "ghcr.io/a/b", Tag: "v1"},
+		"ghcr.io/a/b@sha256:abc":    {FullyQualifiedName: "ghcr.io/a/b", Digest: "sha256:abc"},
+		"ghcr.io/a/b:v1@sha256:abc": {FullyQualifiedName: "ghcr.io/a/b", Tag: "v1", Digest: "sha256:abc"},
+	}
+	for expected, image := range tests {
+		if ref := image.Reference(); ref != expected {
+			t.Errorf("Expected %s, got %s", expected, ref)
+		}
+	}
+}
+
+func TestRuntimeWithMirrors(t *testing.T) {
+	runtime := runtimes.Runtime{
+		Name: "lamp",
+		Images: map[string]runtimes.Image{
+			"mysql": {ShortName: "mysql", FullyQualifiedName: "ghcr.io/sinux-l5d/studentbox/runtime/lamp.mysql"},
+			"other": {ShortName: "other", FullyQualifiedName: "ghcr.io/sinux-l5d/studentboxes/lamp.other"},
+			"hub":   {ShortName: "hub", FullyQualifiedName: "docker.io/library/busybox"},
+		},
+	}
+	mirrors := map[string]string{
+		"ghcr.io":                               "mirror.example.edu/ghcr",
+		"ghcr.io/sinux-l5d/studentbox/runtime/": "registry.example.edu/studentbox/",
+	}
+
+	mirrored := runtime.WithMirrors(mirrors)
+	expected := map[string]string{
+		"mysql": "registry.example.edu/studentbox/lamp.mysql",
+		"other": "mirror.example.edu/ghcr/sinux-l5d/studentboxes/lamp.other",
+		"hub":   "docker.io/library/busybox",
+	}
+	for name, fqn := range expected {
+		if got := mirrored.Images[name].FullyQualifiedName; got != fqn {
+			t.Errorf("Expected %s, got %s", fqn, got)
+		}
+	}
+	if runtime.Images["mysql"].FullyQualifiedName != "ghcr.io/sinux-l5d/studentbox/runtime/lamp.mysql" {
+		t.Errorf("Original runtime was modified")
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    runtimes.RestartPolicy
+		expectError bool
+	}{
+		{"no", runtimes.RestartPolicy{Name: runtimes.RestartNo}, false},
+		{"always", runtimes.RestartPolicy{Name: runtimes.RestartAlways}, false},
+		{"on-failure", runtimes.RestartPolicy{Name: runtimes.RestartOnFailure}, false},
+		{"on-failure:5", runtimes.RestartPolicy{Name: runtimes.RestartOnFailure, MaxRetries: 5}, false},
+		{"on-failure:0", runtimes.RestartPolicy{}, true},
+		{"on-failure:x", runtimes.RestartPolicy{}, true},
+		{"always:3", runtimes.RestartPolicy{}, true},
+		{"unless-stopped", runtimes.RestartPolicy{}, true},
+		{"", runtimes.RestartPolicy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			policy, err := runtimes.ParseRestartPolicy(tt.value)
+			if tt.expectError {
+				var invalid *runtimes.ErrInvalidRestartPolicy
+				if !errors.As(err, &invalid) {
+					t.Fatalf("expected ErrInvalidRestartPolicy, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, policy)
+			}
+			if policy.String() != tt.value {
+				t.Errorf("expected String() %q, got %q", tt.value, policy.String())
+			}
+		})
+	}
+}
+
+func TestRuntimeWithSharedImages(t *testing.T) {
+	runtime := runtimes.Runtime{
+		Name: "lamp",
+		Images: map[string]runtimes.Image{
+			"php":   {ShortName: "php"},
+			"mysql": {ShortName: "mysql", Shared: true},
+			"redis": {ShortName: "redis", Shared: true},
+		},
+	}
+	tests := map[string]struct {
+		names    []string
+		expected []string
+	}{
+		"all":       {[]string{"mysql", "redis"}, []string{"mysql", "redis"}},
+		"one":       {[]string{"redis"}, []string{"redis"}},
+		"none":      {nil, []string{}},
+		"not owned": {[]string{"php"}, []string{}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			shared := runtime.WithSharedImages(tt.names).SharedImages()
+			got := make([]string, len(shared))
+			for i, image := range shared {
+				got[i] = image.ShortName
+			}
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+	if shared := runtime.SharedImages(); len(shared) != 2 {
+		t.Errorf("original runtime changed, got %d shared images", len(shared))
+	}
+}
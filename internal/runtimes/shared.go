@@ -0,0 +1,57 @@
+package runtimes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Value of the studentbox.config.shared label for images shared by all projects of a user
+const SharedPerUser = "user"
+
+type ErrInvalidShared struct {
+	Value string
+}
+
+func (e *ErrInvalidShared) Error() string {
+	return fmt.Sprintf("invalid shared scope \"%s\", expected %s", e.Value, SharedPerUser)
+}
+
+// Parse the value of the studentbox.config.shared label
+func ParseShared(value string) (bool, error) {
+	switch value {
+	case "":
+		return false, nil
+	case SharedPerUser:
+		return true, nil
+	}
+	return false, &ErrInvalidShared{Value: value}
+}
+
+// Images shared per user, sorted by short name
+func (r Runtime) SharedImages() []Image {
+	shared := make([]Image, 0)
+	for _, image := range r.Images {
+		if image.Shared {
+			shared = append(shared, image)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].ShortName < shared[j].ShortName
+	})
+	return shared
+}
+
+// Return a copy of the runtime where only the given images are shared
+// Other images run in the project's pod, even if the runtime shares them
+func (r Runtime) WithSharedImages(names []string) Runtime {
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	shared := Runtime{Name: r.Name, Images: make(map[string]Image, len(r.Images))}
+	for name, image := range r.Images {
+		image.Shared = image.Shared && keep[name]
+		shared.Images[name] = image
+	}
+	return shared
+}
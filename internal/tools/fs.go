@@ -1,13 +1,40 @@
 package tools
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 func EnsureDirCreated(path string) error {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err = os.MkdirAll(path, 0755)
+	return os.MkdirAll(path, 0755)
+}
+
+// Create the directory base/relative, base included
+// Return the topmost directory created by this call below base, empty if none
+// Directories created concurrently by someone else are never returned, so the result can be removed safely
+func EnsureDirCreatedBelow(base, relative string) (string, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return "", err
+	}
+
+	top := ""
+	dir := filepath.Clean(base)
+	for _, name := range strings.Split(filepath.Clean(relative), string(filepath.Separator)) {
+		if name == "" || name == "." {
+			continue
+		}
+		dir = filepath.Join(dir, name)
+		err := os.Mkdir(dir, 0755)
+		if os.IsExist(err) {
+			continue
+		}
 		if err != nil {
-			return err
+			return "", err
+		}
+		if top == "" {
+			top = dir
 		}
 	}
-	return nil
+	return top, nil
 }
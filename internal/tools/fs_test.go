@@ -0,0 +1,41 @@
+package tools_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sinux-l5d/studentbox/internal/tools"
+)
+
+func TestEnsureDirCreatedBelow(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "data", "alice")
+
+	// base is created but never reported
+	top, err := tools.EnsureDirCreatedBelow(base, "tp1/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(base, "tp1"); top != expected {
+		t.Errorf("Expected %s, got %s", expected, top)
+	}
+
+	top, err = tools.EnsureDirCreatedBelow(base, "tp1/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(base, "tp1", "html"); top != expected {
+		t.Errorf("Expected %s, got %s", expected, top)
+	}
+
+	top, err = tools.EnsureDirCreatedBelow(base, "tp1/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top != "" {
+		t.Errorf("Expected nothing to be created, got %s", top)
+	}
+	if info, err := os.Stat(filepath.Join(base, "tp1", "db")); err != nil || !info.IsDir() {
+		t.Errorf("Expected directory to exist, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+// Outgoing webhooks: signed JSON payloads POSTed to configured URLs, with retries
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Headers of webhook requests
+const (
+	HeaderEvent = "X-Studentbox-Event"
+	// sha256=<hex HMAC-SHA256 of the body with the hook's secret>, absent without secret
+	HeaderSignature = "X-Studentbox-Signature"
+)
+
+// A webhook endpoint
+type Hook struct {
+	URL string `json:"url"`
+	// Key to sign payloads with, unsigned if empty
+	Secret string `json:"secret,omitempty"`
+	// Event types sent to this hook, all if empty
+	Events []string `json:"events,omitempty"`
+}
+
+func (h *Hook) wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Read hooks from a JSON file containing a list of Hook
+func LoadHooks(path string) ([]Hook, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []Hook
+	if err := json.Unmarshal(content, &hooks); err != nil {
+		return nil, fmt.Errorf("invalid webhooks file %s: %w", path, err)
+	}
+	for i, hook := range hooks {
+		if hook.URL == "" {
+			return nil, fmt.Errorf("invalid webhooks file %s: hook %d has no url", path, i)
+		}
+	}
+	return hooks, nil
+}
+
+// Body of webhook requests
+type Payload struct {
+	// Unique, for receivers to deduplicate retries
+	ID      string         `json:"id"`
+	Event   string         `json:"event"`
+	Time    time.Time      `json:"time"`
+	User    string         `json:"user,omitempty"`
+	Project string         `json:"project,omitempty"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Payload that couldn't be delivered, appended to the dead-letter file
+type DeadLetter struct {
+	Time    time.Time `json:"time"`
+	URL     string    `json:"url"`
+	Error   string    `json:"error"`
+	Payload Payload   `json:"payload"`
+}
+
+type Options struct {
+	// Attempts after the first one
+	Retries int
+	// Delay before the first retry, doubled for each following one
+	Backoff time.Duration
+	// JSON lines file of undelivered payloads, dropped if empty
+	DeadLetter string
+	// http.DefaultClient with a 10s timeout if nil
+	Client *http.Client
+}
+
+type Dispatcher struct {
+	hooks []Hook
+	opt   Options
+}
+
+func NewDispatcher(hooks []Hook, opt Options) *Dispatcher {
+	if opt.Client == nil {
+		opt.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{hooks: hooks, opt: opt}
+}
+
+// Hex HMAC-SHA256 of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver a payload to every hook wanting its event, retrying failed deliveries
+// Hooks are delivered concurrently, so ctx bounds the whole call even with dead endpoints
+// ID and Time are set if empty
+// Undelivered payloads are written to the dead-letter file and their errors returned joined
+func (d *Dispatcher) Send(ctx context.Context, payload Payload) error {
+	if payload.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		payload.ID = hex.EncodeToString(id)
+	}
+	if payload.Time.IsZero() {
+		payload.Time = time.Now()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i := range d.hooks {
+		hook := &d.hooks[i]
+		if !hook.wants(payload.Event) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := d.deliver(ctx, hook, payload.Event, body)
+			if err == nil {
+				return
+			}
+			// also serializes writes to the dead-letter file
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, fmt.Errorf("failed to deliver %s to %s: %w", payload.Event, hook.URL, err))
+			if dlErr := d.deadLetter(hook.URL, payload, err); dlErr != nil {
+				errs = append(errs, fmt.Errorf("failed to write dead letter: %w", dlErr))
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, hook *Hook, event string, body []byte) error {
+	backoff := d.opt.Backoff
+	var err error
+	for attempt := 0; attempt <= d.opt.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			}
+			backoff *= 2
+		}
+		var retry bool
+		if retry, err = d.post(ctx, hook, event, body); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// POST the payload once, reporting whether a failure is worth retrying
+func (d *Dispatcher) post(ctx context.Context, hook *Hook, event string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "studentbox-webhook")
+	req.Header.Set(HeaderEvent, event)
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, body))
+	}
+
+	resp, err := d.opt.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	// other client errors won't get better by retrying
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+func (d *Dispatcher) deadLetter(url string, payload Payload, err error) error {
+	if d.opt.DeadLetter == "" {
+		return nil
+	}
+	line, mErr := json.Marshal(DeadLetter{Time: time.Now(), URL: url, Error: err.Error(), Payload: payload})
+	if mErr != nil {
+		return mErr
+	}
+	file, oErr := os.OpenFile(d.opt.DeadLetter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if oErr != nil {
+		return oErr
+	}
+	defer file.Close()
+	_, wErr := file.Write(append(line, '\n'))
+	return wErr
+}
@@ -0,0 +1,137 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sinux-l5d/studentbox/internal/webhook"
+)
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses returned by the stub, the last one is repeated
+		statuses  []int
+		retries   int
+		delivered bool
+		attempts  int32
+	}{
+		{"ok", []int{http.StatusNoContent}, 2, true, 1},
+		{"retried", []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK}, 2, true, 3},
+		{"exhausted", []int{http.StatusInternalServerError}, 2, false, 3},
+		{"not retried", []int{http.StatusBadRequest}, 2, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			var body []byte
+			var signature, event string
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&attempts, 1))
+				body, _ = io.ReadAll(r.Body)
+				signature, event = r.Header.Get(webhook.HeaderSignature), r.Header.Get(webhook.HeaderEvent)
+				if n > len(tt.statuses) {
+					n = len(tt.statuses)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer stub.Close()
+
+			deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+			d := webhook.NewDispatcher([]webhook.Hook{
+				{URL: stub.URL, Secret: "s3cret", Events: []string{"container-died"}},
+			}, webhook.Options{Retries: tt.retries, Backoff: time.Millisecond, DeadLetter: deadLetter})
+
+			err := d.Send(context.Background(), webhook.Payload{Event: "container-died", User: "alice", Project: "web"})
+			if (err == nil) != tt.delivered {
+				t.Fatalf("expected delivered %v, got error %v", tt.delivered, err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, attempts)
+			}
+			if event != "container-died" {
+				t.Errorf("expected event header, got %q", event)
+			}
+			if signature != "sha256="+webhook.Sign("s3cret", body) {
+				t.Errorf("invalid signature %q", signature)
+			}
+
+			dead, err := os.ReadFile(deadLetter)
+			if tt.delivered {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no dead letter, got %q", dead)
+				}
+				return
+			}
+			var letter webhook.DeadLetter
+			if err := json.Unmarshal(dead, &letter); err != nil {
+				t.Fatal(err)
+			}
+			if letter.URL != stub.URL || letter.Payload.User != "alice" || !strings.Contains(letter.Error, "unexpected status") {
+				t.Errorf("unexpected dead letter %+v", letter)
+			}
+		})
+	}
+}
+
+func TestSendFiltersEvents(t *testing.T) {
+	var attempts int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+	}))
+	defer stub.Close()
+
+	d := webhook.NewDispatcher([]webhook.Hook{{URL: stub.URL, Events: []string{"expiry-warning"}}}, webhook.Options{})
+	if err := d.Send(context.Background(), webhook.Payload{Event: "spawn-succeeded"}); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 0 {
+		t.Errorf("expected event to be filtered, got %d attempts", attempts)
+	}
+}
+
+func TestSendBoundedByContext(t *testing.T) {
+	var attempts int32
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer dead.Close()
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+	}))
+	defer alive.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.jsonl")
+	d := webhook.NewDispatcher([]webhook.Hook{{URL: dead.URL}, {URL: alive.URL}},
+		webhook.Options{Retries: 3, Backoff: time.Second, DeadLetter: deadLetter})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := d.Send(ctx, webhook.Payload{Event: "spawn-failed"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Send to return with its context, took %s", elapsed)
+	}
+	if err == nil {
+		t.Fatal("expected the dead endpoint to fail")
+	}
+	if attempts != 1 {
+		t.Errorf("expected the other hook to be delivered, got %d attempts", attempts)
+	}
+	if content, err := os.ReadFile(deadLetter); err != nil || !strings.Contains(string(content), dead.URL) {
+		t.Errorf("expected a dead letter for %s, got %q (%v)", dead.URL, content, err)
+	}
+}
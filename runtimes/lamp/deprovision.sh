@@ -0,0 +1,16 @@
+#!/bin/sh
+# Drop the database and account of a destroyed project from the user's shared server
+# Input: the same env vars as studentbox-provision, with the project's connection env vars
+set -eu
+
+database="${STUDENTBOX_ENV_MARIADB_DATABASE:-$STUDENTBOX_PROJECT}"
+user="${STUDENTBOX_ENV_MARIADB_USER:-$STUDENTBOX_PROJECT}"
+
+# escape identifiers and strings for SQL
+ident() { printf '%s' "$1" | sed 's/`/``/g'; }
+str() { printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e "s/'/''/g"; }
+
+MYSQL_PWD="$MARIADB_ROOT_PASSWORD" mariadb -uroot -h127.0.0.1 <<SQL
+DROP DATABASE IF EXISTS \`$(ident "$database")\`;
+DROP USER IF EXISTS '$(str "$user")'@'%';
+SQL
@@ -0,0 +1,9 @@
+#!/bin/sh
+# Dump the database of a project from the user's shared server, for snapshots
+# Input: the same env vars as studentbox-provision, with the project's connection env vars
+# Output: the SQL dump of the project's database
+set -eu
+
+database="${STUDENTBOX_ENV_MARIADB_DATABASE:-$STUDENTBOX_PROJECT}"
+
+MYSQL_PWD="$MARIADB_ROOT_PASSWORD" exec mariadb-dump -uroot -h127.0.0.1 --single-transaction --routines --triggers --databases "$database"
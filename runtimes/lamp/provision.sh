@@ -0,0 +1,25 @@
+#!/bin/sh
+# Create the database and account of a project in the user's shared server
+# Input: STUDENTBOX_USER, STUDENTBOX_PROJECT and the project's env vars prefixed with STUDENTBOX_ENV_, if any
+# The server's own MARIADB_* env vars are not the project's, they are ignored
+# Output: the project's connection env vars, one KEY=VALUE per line
+set -eu
+
+database="${STUDENTBOX_ENV_MARIADB_DATABASE:-$STUDENTBOX_PROJECT}"
+user="${STUDENTBOX_ENV_MARIADB_USER:-$STUDENTBOX_PROJECT}"
+password="${STUDENTBOX_ENV_MARIADB_PASSWORD:-$(tr -dc 'A-Za-z0-9' </dev/urandom | head -c 16)}"
+
+# escape identifiers and strings for SQL
+ident() { printf '%s' "$1" | sed 's/`/``/g'; }
+str() { printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e "s/'/''/g"; }
+
+MYSQL_PWD="$MARIADB_ROOT_PASSWORD" mariadb -uroot -h127.0.0.1 <<SQL
+CREATE DATABASE IF NOT EXISTS \`$(ident "$database")\`;
+CREATE USER IF NOT EXISTS '$(str "$user")'@'%' IDENTIFIED BY '$(str "$password")';
+ALTER USER '$(str "$user")'@'%' IDENTIFIED BY '$(str "$password")';
+GRANT ALL PRIVILEGES ON \`$(ident "$database")\`.* TO '$(str "$user")'@'%';
+SQL
+
+echo "MARIADB_DATABASE=$database"
+echo "MARIADB_USER=$user"
+echo "MARIADB_PASSWORD=$password"